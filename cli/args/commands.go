@@ -0,0 +1,216 @@
+package args
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Represents a CLI command, the flags it accepts and its help text
+type command struct {
+	// Name typed on the command line
+	name string
+
+	// One line summary shown in the general help
+	summary string
+
+	// Usage line and description shown by binman help <command>
+	usage string
+
+	// Flags the command accepts, without their value i.e --platforms
+	flags []string
+
+	// If the command needs a binman.yml in the path
+	needsConfig bool
+
+	// If the command output is meant for scripts so nothing else should be printed
+	quiet bool
+}
+
+// Flags shared by commands working on a selection of targets
+var targetFlags = []string{"--platforms", "--architectures"}
+
+// List of commands in the order they are shown in the help
+var commands = []command{
+	{
+		name:        "install",
+		summary:     "Download, verify and install binaries into bin (the default command)",
+		needsConfig: true,
+		flags: append(slices.Clone(targetFlags),
+			"--no-clean", "--concurrency", "--retries", "--timeout", "--force", "--no-cache", "--offline", "--seed-dir", "--frozen",
+			"--max-extracted-size", "--max-entries", "--max-ratio", "--max-download-size", "--arch-check",
+		),
+		usage: `Usage: binman install [path] [..flags..]
+
+Downloads every binary in <path>/binman.yml, verifies its SHA256 and installs it into
+<path>/bin/<name>/<platform>/<arch>. Only targets whose config changed are installed again.
+What was installed is recorded in <path>/binman.lock.
+Running binman <path> without a command does the same.
+
+Flags
+  --platforms=linux,windows   comma separated platforms to install
+  --architectures=x64         comma separated architectures to install
+  --no-clean                  keep every file instead of only the ones matching the pattern
+  --concurrency=N             how many downloads run at the same time, defaults to the number of CPUs
+  --retries=N                 how many times a failed download is retried, defaults to 3
+  --timeout=30s               how long to wait to connect or for more data, defaults to 30s
+  --force                     install every binary again even if it is up to date
+  --no-cache                  do not use the download cache
+  --offline                   install only from the download cache or --seed-dir
+  --seed-dir=path             folder of pre-downloaded archives checked before the cache
+  --frozen                    fail if the config or the installed files differ from binman.lock
+  --max-extracted-size=8GiB   total bytes an archive may extract to, 0 for no limit
+  --max-entries=100000        number of entries an archive may hold, 0 for no limit
+  --max-ratio=1000            how many times larger than the archive its content may be, 0 for no limit
+  --max-download-size=4GiB    bytes a download may have, 0 for no limit
+  --arch-check=warn           warn, error or off when an executable is built for another platform or architecture`,
+	},
+	{
+		name:        "verify",
+		summary:     "Re-hash installed files and cached archives against the config",
+		needsConfig: true,
+		flags:       append(slices.Clone(targetFlags), "--resolve-checksums"),
+		usage: `Usage: binman verify [path] [..flags..]
+
+Checks that every target in <path>/binman.yml is installed from the SHA256 in the config,
+that the installed files still match the hashes recorded at install time and that cached
+archives still match their SHA256. Exits with an error if anything does not match.
+Targets hashed through a checksum_url are checked against the hash recorded at install,
+nothing is downloaded unless --resolve-checksums is set.
+
+Flags
+  --platforms=linux,windows   comma separated platforms to verify
+  --architectures=x64         comma separated architectures to verify
+  --resolve-checksums         download checksum_url files and verify against them`,
+	},
+	{
+		name:        "list",
+		summary:     "Show configured binaries and their install status",
+		needsConfig: true,
+		flags:       slices.Clone(targetFlags),
+		usage: `Usage: binman list [path] [..flags..]
+
+Lists every binary, platform and architecture in <path>/binman.yml and whether it is
+installed, outdated or missing.
+
+Flags
+  --platforms=linux,windows   comma separated platforms to list
+  --architectures=x64         comma separated architectures to list`,
+	},
+	{
+		name:    "clean",
+		summary: "Remove the bin and downloads folders",
+		usage: `Usage: binman clean [path]
+
+Removes <path>/bin and <path>/downloads, the next install starts from scratch.`,
+	},
+	{
+		name:        "which",
+		summary:     "Print the path of a binary's executable for this machine",
+		needsConfig: true,
+		quiet:       true,
+		flags:       []string{"--platform", "--arch"},
+		usage: `Usage: binman which <name> [path] [..flags..]
+
+Prints the path of the installed executable of <name> for the platform and architecture
+of this machine, found with the pattern defined for it in <path>/binman.yml.
+Go's GOOS and GOARCH are mapped to binman's names, i.e amd64 is x64 and 386 is ia32.
+
+Flags
+  --platform=linux   platform to resolve for instead of this machine's
+  --arch=arm64       architecture to resolve for instead of this machine's`,
+	},
+	{
+		name:    "init",
+		summary: "Create an example binman.yml",
+		flags:   []string{"--force"},
+		usage: `Usage: binman init [path] [..flags..]
+
+Writes an example <path>/binman.yml to start from.
+
+Flags
+  --force   overwrite an existing binman.yml`,
+	},
+	{
+		name:        "hash",
+		summary:     "Download URLs and write their hashes into binman.yml",
+		needsConfig: true,
+		flags: append(slices.Clone(targetFlags),
+			"--check", "--concurrency", "--retries", "--timeout", "--no-cache", "--max-download-size",
+		),
+		usage: `Usage: binman hash [path] [..flags..]
+
+Downloads every URL in <path>/binman.yml whose sha256 or integrity is missing or a placeholder
+and writes the hash of what it served into the file, keeping comments and key order.
+An integrity placeholder such as sha512:TODO is filled in with that algorithm.
+The config does not need to be valid yet, so this can fill in a new binary.
+
+Flags
+  --check                     download every URL and report hashes that do not match, write nothing
+  --platforms=linux,windows   comma separated platforms to hash
+  --architectures=x64         comma separated architectures to hash
+  --concurrency=N             how many downloads run at the same time, defaults to the number of CPUs
+  --retries=N                 how many times a failed download is retried, defaults to 3
+  --timeout=30s               how long to wait to connect or for more data, defaults to 30s
+  --no-cache                  do not add the downloaded archives to the download cache
+  --max-download-size=4GiB    bytes a download may have, 0 for no limit`,
+	},
+	{
+		name:    "cache",
+		summary: "Manage the shared download cache",
+		flags:   []string{"--max-age"},
+		usage: `Usage: binman cache <ls|prune|clear> [..flags..]
+
+  ls      list cached archives with their size and when they were last used
+  prune   remove archives not used within --max-age and any that no longer match their hash
+  clear   remove the whole cache
+
+Flags
+  --max-age=720h   used by prune, defaults to 30 days`,
+	},
+}
+
+// findCommand returns the command with the given name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// accepts reports whether the command takes the flag, given with or without its value
+func (c command) accepts(arg string) bool {
+	name, _, _ := strings.Cut(arg, "=")
+	return slices.Contains(c.flags, name)
+}
+
+// Usage returns the general help listing every command
+func Usage() string {
+	var b strings.Builder
+	b.WriteString("Usage: binman <command> [path] [..flags..]\n\nCommands\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	b.WriteString("\nRun binman help <command> for the flags of a command.")
+	return b.String()
+}
+
+// printHelp prints the help of a command, or the general help when name is empty, and exits
+func printHelp(name string) {
+	if name == "" {
+		fmt.Println(Usage())
+		os.Exit(0)
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Unknown command: "+name+"\n\n"+Usage())
+		os.Exit(1)
+	}
+
+	fmt.Println(cmd.usage)
+	os.Exit(0)
+}
@@ -3,9 +3,13 @@ package args
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/units"
 )
 
 // List of options passed from the CLI mapped to fields
@@ -24,6 +28,70 @@ type Options struct {
 
 	// If it should run the clean logic i.e via the pattern regex for the platform
 	NoClean bool
+
+	// How many downloads can run at the same time - defaults to the number of CPUs
+	Concurrency int
+
+	// How many times a failed download is retried - defaults to 3
+	Retries int
+
+	// How long to wait for a connection or for more data before a download attempt fails - defaults to 30s
+	Timeout time.Duration
+
+	// If everything should be installed again, ignoring what the state file says is up to date
+	Force bool
+
+	// If downloads should skip the shared download cache
+	NoCache bool
+
+	// If archives must come from the download cache or the seed directory without any network access
+	Offline bool
+
+	// Folder of pre-downloaded archives checked before the cache and the network - defaults to empty
+	SeedDir string
+
+	// If the install must match binman.lock exactly, failing instead of updating it
+	Frozen bool
+
+	// If the hash command only reports sha256 entries that do not match instead of writing them
+	Check bool
+
+	// If verify downloads checksum files instead of using the hashes recorded at install
+	ResolveChecksums bool
+
+	// Total bytes an archive may extract to, 0 for no limit - defaults to 8 GiB
+	MaxExtractedSize int64
+
+	// Number of entries an archive may hold, 0 for no limit - defaults to 100000
+	MaxEntries int
+
+	// How many times larger than the archive its extracted content may be, 0 for no limit - defaults to 1000
+	MaxRatio float64
+
+	// Bytes a download may have, 0 for no limit - defaults to 4 GiB
+	MaxDownloadSize int64
+
+	// What happens when an installed executable is built for another platform or architecture,
+	// one of warn, error or off - defaults to warn
+	ArchCheck string
+
+	// The command to run i.e install, verify, list, clean, which, init, cache or hash - defaults to install
+	Command string
+
+	// Name of the binary passed to the which command
+	BinaryName string
+
+	// Platform the which command resolves for - defaults to the platform of this machine
+	ResolvePlatform string
+
+	// Architecture the which command resolves for - defaults to the architecture of this machine
+	ResolveArch string
+
+	// What the cache command does, one of ls, prune or clear
+	CacheAction string
+
+	// Cached archives not used for this long are removed by cache prune - defaults to 30 days
+	CacheMaxAge time.Duration
 }
 
 // Parse args passed to the cli and get the options
@@ -34,6 +102,27 @@ func Parse() *Options {
 		SpecificPlatformBuilds: []string{},
 		SpecificArchBuilds:     []string{},
 		NoClean:                false,
+		Concurrency:            runtime.NumCPU(),
+		Retries:                3,
+		Timeout:                30 * time.Second,
+		Force:                  false,
+		NoCache:                false,
+		Offline:                false,
+		SeedDir:                "",
+		Frozen:                 false,
+		Check:                  false,
+		ResolveChecksums:       false,
+		MaxExtractedSize:       8 << 30,
+		MaxEntries:             100000,
+		MaxRatio:               1000,
+		MaxDownloadSize:        4 << 30,
+		ArchCheck:              "warn",
+		Command:                "install",
+		BinaryName:             "",
+		ResolvePlatform:        "",
+		ResolveArch:            "",
+		CacheAction:            "",
+		CacheMaxAge:            30 * 24 * time.Hour,
 	}
 	setOptions(options)
 
@@ -45,10 +134,83 @@ func setOptions(options *Options) {
 	args := os.Args[1:]
 
 	if len(args) == 0 {
-		printer.ExitError("Missing path argument. Usage: binman <path> [..flags..]")
+		printer.ExitError("Missing command or path argument.\n\n" + Usage())
+	}
+
+	switch args[0] {
+	case "help", "--help", "-h":
+		name := ""
+		if len(args) > 1 {
+			name = args[1]
+		}
+		printHelp(name)
+	}
+
+	// Without a command binman installs, keeping binman <path> [..flags..] working
+	cmd, ok := findCommand(args[0])
+	if ok {
+		args = args[1:]
+	} else {
+		cmd, _ = findCommand("install")
+	}
+	options.Command = cmd.name
+
+	var positional, flags []string
+	for _, arg := range args {
+		switch {
+		case arg == "--help" || arg == "-h":
+			printHelp(cmd.name)
+		case strings.HasPrefix(arg, "-"):
+			flags = append(flags, arg)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	switch cmd.name {
+	case "cache":
+		if len(positional) == 0 {
+			printer.ExitError("Missing cache action.\n\n" + cmd.usage)
+		}
+		switch positional[0] {
+		case "ls", "prune", "clear":
+			options.CacheAction = positional[0]
+		default:
+			printer.ExitError("Unknown cache action: " + positional[0] + "\n\n" + cmd.usage)
+		}
+		if len(positional) > 1 {
+			printer.ExitError("Too many arguments.\n\n" + cmd.usage)
+		}
+		positional = nil
+	case "which":
+		if len(positional) == 0 {
+			printer.ExitError("Missing binary name.\n\n" + cmd.usage)
+		}
+		options.BinaryName = positional[0]
+		positional = positional[1:]
+		fallthrough
+	default:
+		if len(positional) > 1 {
+			printer.ExitError("Too many arguments.\n\n" + cmd.usage)
+		}
+
+		inputPath := "."
+		if len(positional) == 1 {
+			inputPath = positional[0]
+		}
+		setPath(options, cmd, inputPath)
+	}
+
+	for _, arg := range flags {
+		if !cmd.accepts(arg) {
+			printer.ExitError("Unknown flag for " + cmd.name + ": " + arg + "\n\n" + cmd.usage)
+		}
+		setFlag(options, arg)
 	}
+}
 
-	inputPath := args[0]
+// Resolves the path the command runs in and the config file inside it
+func setPath(options *Options, cmd command, inputPath string) {
 	absPath, err := filepath.Abs(inputPath)
 	if err != nil {
 		printer.ExitError("Failed to resolve path: " + err.Error())
@@ -63,30 +225,133 @@ func setOptions(options *Options) {
 	}
 
 	options.Path = absPath
+	options.PathToFile = filepath.Join(absPath, "binman.yml")
+
+	if cmd.quiet {
+		if _, err := os.Stat(options.PathToFile); cmd.needsConfig && os.IsNotExist(err) {
+			printer.ExitError("Missing required config file: " + options.PathToFile)
+		}
+		return
+	}
+
 	printer.PrintSuccess("Resolved path: " + absPath)
 
-	configPath := filepath.Join(absPath, "binman.yml")
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		printer.ExitError("Missing required config file: " + configPath)
+	if !cmd.needsConfig {
+		return
 	}
 
-	options.PathToFile = configPath
-	printer.PrintSuccess("Found config file: " + configPath)
+	if _, err := os.Stat(options.PathToFile); os.IsNotExist(err) {
+		printer.ExitError("Missing required config file: " + options.PathToFile)
+	}
 
-	for _, arg := range args[1:] {
-		switch {
-		case strings.HasPrefix(arg, "--platforms="):
-			value := strings.TrimPrefix(arg, "--platforms=")
-			options.SpecificPlatformBuilds = strings.Split(value, ",")
-			printer.PrintSuccess("Target platforms: " + strings.Join(options.SpecificPlatformBuilds, ", "))
-		case strings.HasPrefix(arg, "--architectures="):
-			value := strings.TrimPrefix(arg, "--architectures=")
-			options.SpecificArchBuilds = strings.Split(value, ",")
-			printer.PrintSuccess("Target architectures: " + strings.Join(options.SpecificArchBuilds, ", "))
-		case arg == "--no-clean":
-			options.NoClean = true
-		default:
-			printer.ExitError("Unknown flag: " + arg)
+	printer.PrintSuccess("Found config file: " + options.PathToFile)
+}
+
+// Sets the option of a single flag
+func setFlag(options *Options, arg string) {
+	switch {
+	case strings.HasPrefix(arg, "--platforms="):
+		value := strings.TrimPrefix(arg, "--platforms=")
+		options.SpecificPlatformBuilds = strings.Split(value, ",")
+		printer.PrintSuccess("Target platforms: " + strings.Join(options.SpecificPlatformBuilds, ", "))
+	case strings.HasPrefix(arg, "--architectures="):
+		value := strings.TrimPrefix(arg, "--architectures=")
+		options.SpecificArchBuilds = strings.Split(value, ",")
+		printer.PrintSuccess("Target architectures: " + strings.Join(options.SpecificArchBuilds, ", "))
+	case arg == "--no-clean":
+		options.NoClean = true
+	case strings.HasPrefix(arg, "--concurrency="):
+		value := strings.TrimPrefix(arg, "--concurrency=")
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			printer.ExitError("Invalid concurrency, expected a positive number: " + value)
+		}
+		options.Concurrency = n
+		printer.PrintSuccess("Concurrency: " + value)
+	case strings.HasPrefix(arg, "--retries="):
+		value := strings.TrimPrefix(arg, "--retries=")
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			printer.ExitError("Invalid retries, expected zero or a positive number: " + value)
+		}
+		options.Retries = n
+	case strings.HasPrefix(arg, "--timeout="):
+		value := strings.TrimPrefix(arg, "--timeout=")
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			printer.ExitError("Invalid timeout, expected a duration like 30s: " + value)
+		}
+		options.Timeout = timeout
+	case arg == "--force":
+		options.Force = true
+	case arg == "--no-cache":
+		options.NoCache = true
+	case arg == "--offline":
+		options.Offline = true
+		printer.PrintSuccess("Offline mode, archives must come from the cache or seed directory")
+	case arg == "--frozen":
+		options.Frozen = true
+	case arg == "--check":
+		options.Check = true
+	case arg == "--resolve-checksums":
+		options.ResolveChecksums = true
+	case strings.HasPrefix(arg, "--seed-dir="):
+		value := strings.TrimPrefix(arg, "--seed-dir=")
+		seedDir, err := filepath.Abs(value)
+		if err != nil {
+			printer.ExitError("Failed to resolve seed directory: " + err.Error())
+		}
+		if info, err := os.Stat(seedDir); err != nil || !info.IsDir() {
+			printer.ExitError("Seed directory does not exist: " + seedDir)
+		}
+		options.SeedDir = seedDir
+		printer.PrintSuccess("Seed directory: " + seedDir)
+	case strings.HasPrefix(arg, "--platform="):
+		options.ResolvePlatform = strings.TrimPrefix(arg, "--platform=")
+	case strings.HasPrefix(arg, "--arch="):
+		options.ResolveArch = strings.TrimPrefix(arg, "--arch=")
+	case strings.HasPrefix(arg, "--max-extracted-size="):
+		value := strings.TrimPrefix(arg, "--max-extracted-size=")
+		size, err := units.ParseBytes(value)
+		if err != nil {
+			printer.ExitError("Invalid max extracted size: " + err.Error())
+		}
+		options.MaxExtractedSize = size
+	case strings.HasPrefix(arg, "--max-entries="):
+		value := strings.TrimPrefix(arg, "--max-entries=")
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			printer.ExitError("Invalid max entries, expected a number of 0 or more: " + value)
+		}
+		options.MaxEntries = n
+	case strings.HasPrefix(arg, "--max-ratio="):
+		value := strings.TrimPrefix(arg, "--max-ratio=")
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio < 0 {
+			printer.ExitError("Invalid max ratio, expected a number of 0 or more: " + value)
+		}
+		options.MaxRatio = ratio
+	case strings.HasPrefix(arg, "--max-download-size="):
+		value := strings.TrimPrefix(arg, "--max-download-size=")
+		size, err := units.ParseBytes(value)
+		if err != nil {
+			printer.ExitError("Invalid max download size: " + err.Error())
+		}
+		options.MaxDownloadSize = size
+	case strings.HasPrefix(arg, "--arch-check="):
+		value := strings.TrimPrefix(arg, "--arch-check=")
+		if value != "warn" && value != "error" && value != "off" {
+			printer.ExitError("Invalid arch check, expected warn, error or off: " + value)
+		}
+		options.ArchCheck = value
+	case strings.HasPrefix(arg, "--max-age="):
+		value := strings.TrimPrefix(arg, "--max-age=")
+		maxAge, err := time.ParseDuration(value)
+		if err != nil || maxAge < 0 {
+			printer.ExitError("Invalid max age, expected a duration like 720h: " + value)
 		}
+		options.CacheMaxAge = maxAge
+	default:
+		printer.ExitError("Unknown flag: " + arg)
 	}
 }
@@ -0,0 +1,276 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+)
+
+// Represents an archive stored in the cache, at <algorithm>/<hex> under the cache folder
+type Entry struct {
+	// Hash of the archive, its folder and file name
+	Digest integrity.Digest
+
+	// Full path of the archive in the cache
+	Path string
+
+	// Size of the archive in bytes
+	Size int64
+
+	// Last time the archive was stored or used
+	LastUsed time.Time
+}
+
+// Dir returns the root folder of the cache, $XDG_CACHE_HOME/binman when XDG_CACHE_HOME is set
+// and the user cache directory of the OS otherwise
+func Dir() (string, error) {
+	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "binman"), nil
+	}
+
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user cache directory: %w", err)
+	}
+
+	return filepath.Join(base, "binman"), nil
+}
+
+// Path returns where an archive with the given digest is stored in the cache
+func Path(digest integrity.Digest) (string, error) {
+	if !integrity.IsHex(digest.Algorithm, digest.Hex) {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, digest.Algorithm, digest.Hex), nil
+}
+
+// Lookup returns the path of the cached archive with the given digest and if it exists.
+// A hit marks the archive as used so prune keeps it.
+func Lookup(digest integrity.Digest) (string, bool) {
+	path, err := Path(digest)
+	if err != nil {
+		return "", false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return path, true
+}
+
+// Store copies the file at src into the cache under the given digest.
+// The caller is expected to have verified the hash already.
+func Store(src string, digest integrity.Digest) error {
+	dst, err := Path(digest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write to a temporary file first so a reader never sees a partial archive
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	in, err := os.Open(src)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to copy %s into the cache: %w", src, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("failed to store %s in the cache: %w", src, err)
+	}
+
+	return nil
+}
+
+// CopyTo places a copy of the cached archive with the given digest at dst. It is never hard linked,
+// as the archive is extracted next to itself and an entry of the same name would write into the cache.
+func CopyTo(digest integrity.Digest, dst string) error {
+	src, ok := Lookup(digest)
+	if !ok {
+		return fmt.Errorf("%s is not in the cache", digest)
+	}
+
+	return CopyFile(src, dst)
+}
+
+// CopyFile copies src to dst through a temporary file next to dst, so whatever is at dst,
+// possibly a hard link to another file, is replaced and never written to
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// CreateTemp makes the file private, the copy gets the permissions of src
+	if info, err := in.Stat(); err == nil {
+		os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+// Remove deletes the archive with the given digest from the cache if present
+func Remove(digest integrity.Digest) error {
+	path, err := Path(digest)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// List returns every archive in the cache sorted by digest
+func List() ([]Entry, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	err = walk(dir, func(entry Entry, valid bool) error {
+		if valid {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Digest.String() < entries[j].Digest.String() })
+
+	return entries, nil
+}
+
+// Prune removes archives and temporary files not used within maxAge and archives whose
+// content no longer matches their hash. It returns what was removed.
+func Prune(maxAge time.Duration) ([]Entry, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	var removed []Entry
+
+	err = walk(dir, func(entry Entry, valid bool) error {
+		stale := entry.LastUsed.Before(cutoff)
+		if !stale && valid {
+			stale = integrity.Verify(entry.Path, entry.Digest) != nil
+		}
+
+		if !stale {
+			return nil
+		}
+
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", entry.Path, err)
+		}
+		removed = append(removed, entry)
+		return nil
+	})
+
+	return removed, err
+}
+
+// walk calls fn for everything in the folder of every registered algorithm, valid is false
+// for anything that is not an archive named by its hash such as a leftover temporary file
+func walk(dir string, fn func(entry Entry, valid bool) error) error {
+	for _, algorithm := range integrity.Algorithms() {
+		files, err := os.ReadDir(filepath.Join(dir, algorithm))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			info, err := file.Info()
+			if err != nil {
+				return err
+			}
+
+			entry := Entry{
+				Digest:   integrity.Digest{Algorithm: algorithm, Hex: file.Name()},
+				Path:     filepath.Join(dir, algorithm, file.Name()),
+				Size:     info.Size(),
+				LastUsed: info.ModTime(),
+			}
+			if err := fn(entry, !file.IsDir() && integrity.IsHex(algorithm, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Clear removes the whole cache
+func Clear() error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
@@ -3,13 +3,104 @@ package cleaner
 import (
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/UmbrellaCrow612/binman/cli/args"
 	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+	"github.com/UmbrellaCrow612/binman/cli/stage"
+	"github.com/UmbrellaCrow612/binman/cli/state"
 )
 
-// CleanStart removes the "bin" and "downloads" folders at the beginning of the process.
+// CleanStart removes the "downloads" folder and staged installs left over by an interrupted run
+// at the beginning of the process, and the "bin" folder as well when a full reinstall is forced.
 func CleanStart(options *args.Options) error {
+	dirs := []string{
+		filepath.Join(options.Path, "downloads"),
+		stage.Path(options),
+	}
+	if options.Force {
+		dirs = append(dirs, filepath.Join(options.Path, "bin"))
+	}
+
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		printer.PrintSuccess("Removed " + dir)
+	}
+	return nil
+}
+
+// RemoveStale removes everything in the "bin" folder that the config no longer defines,
+// whole binaries as well as single platforms or architectures, and forgets them in the state.
+func RemoveStale(config *shared.Config, st *state.State, options *args.Options) error {
+	binDir := filepath.Join(options.Path, "bin")
+
+	binaries := map[string]*shared.Binary{}
+	for i := range config.Binaries {
+		binaries[config.Binaries[i].NAME] = &config.Binaries[i]
+	}
+
+	for key, entry := range st.Targets {
+		bin, ok := binaries[entry.Name]
+		if !ok {
+			st.Delete(key)
+			continue
+		}
+		if _, ok := bin.URLS[entry.Platform][entry.Arch]; !ok {
+			st.Delete(key)
+		}
+	}
+
+	names, err := readDirNames(binDir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		bin, ok := binaries[name]
+		if !ok {
+			if err := removeStale(filepath.Join(binDir, name)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		platforms, err := readDirNames(filepath.Join(binDir, name))
+		if err != nil {
+			return err
+		}
+
+		for _, platform := range platforms {
+			archAndUrl, ok := bin.URLS[platform]
+			if !ok {
+				if err := removeStale(filepath.Join(binDir, name, platform)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			arches, err := readDirNames(filepath.Join(binDir, name, platform))
+			if err != nil {
+				return err
+			}
+
+			for _, arch := range arches {
+				if _, ok := archAndUrl[arch]; !ok {
+					if err := removeStale(filepath.Join(binDir, name, platform, arch)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// CleanAll removes the "bin" and "downloads" folders, forgetting everything that was installed
+func CleanAll(options *args.Options) error {
 	dirs := []string{
 		filepath.Join(options.Path, "bin"),
 		filepath.Join(options.Path, "downloads"),
@@ -29,3 +120,30 @@ func CleanEnd(options *args.Options) error {
 	downloadsDir := filepath.Join(options.Path, "downloads")
 	return os.RemoveAll(downloadsDir)
 }
+
+// readDirNames lists the folders in dir, ignoring hidden ones binman keeps its own files in
+func readDirNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func removeStale(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	printer.PrintSuccess("Removed " + dir + " as it is no longer in the config")
+	return nil
+}
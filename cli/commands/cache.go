@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/cache"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+)
+
+// Runs the cache command selected by options.CacheAction
+func Cache(options *args.Options) {
+	dir, err := cache.Dir()
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	switch options.CacheAction {
+	case "ls":
+		entries, err := cache.List()
+		if err != nil {
+			printer.ExitError("Failed to list cache: " + err.Error())
+		}
+
+		var total int64
+		for _, entry := range entries {
+			fmt.Printf("%s  %10d  %s\n", entry.Digest, entry.Size, entry.LastUsed.Format("2006-01-02 15:04:05"))
+			total += entry.Size
+		}
+		printer.PrintSuccess(fmt.Sprintf("%d archives, %d bytes in %s", len(entries), total, dir))
+
+	case "prune":
+		removed, err := cache.Prune(options.CacheMaxAge)
+		for _, entry := range removed {
+			printer.PrintSuccess("Removed " + entry.Path)
+		}
+		if err != nil {
+			printer.ExitError("Failed to prune cache: " + err.Error())
+		}
+		printer.PrintSuccess(fmt.Sprintf("Pruned %d entries from %s", len(removed), dir))
+
+	case "clear":
+		if err := cache.Clear(); err != nil {
+			printer.ExitError("Failed to clear cache: " + err.Error())
+		}
+		printer.PrintSuccess("Removed " + dir)
+	}
+}
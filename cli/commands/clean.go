@@ -0,0 +1,14 @@
+package commands
+
+import (
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/cleaner"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+)
+
+// Removes the bin and downloads folders
+func Clean(options *args.Options) {
+	if err := cleaner.CleanAll(options); err != nil {
+		printer.ExitError(err.Error())
+	}
+}
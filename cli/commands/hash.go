@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/fetch"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/yml"
+)
+
+// Downloads every URL in binman.yml whose sha256 or integrity is missing or a placeholder and writes
+// the hash it computed into the file. With --check every URL is downloaded and compared
+// against the config instead, nothing is written.
+func Hash(options *args.Options) {
+	doc, err := yml.LoadDocument(options.PathToFile)
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	checksums, err := doc.Checksums()
+	if err != nil {
+		printer.ExitError("Failed to parse YAML: " + err.Error())
+	}
+
+	var selected []yml.Checksum
+	for _, checksum := range checksums {
+		if len(options.SpecificPlatformBuilds) > 0 && !slices.Contains(options.SpecificPlatformBuilds, checksum.Platform) {
+			continue
+		}
+		if len(options.SpecificArchBuilds) > 0 && !slices.Contains(options.SpecificArchBuilds, checksum.Arch) {
+			continue
+		}
+		if !options.Check && checksum.IsSet() {
+			continue
+		}
+		if checksum.Value == "" && checksum.ChecksumURL != "" {
+			// Verified against the checksum file at install
+			continue
+		}
+		selected = append(selected, checksum)
+	}
+
+	if len(selected) == 0 {
+		printer.PrintSuccess("Every hash is already set")
+		return
+	}
+
+	// The same archive is often used by several targets, download it once
+	var urls []string
+	var algorithms [][]string
+	for _, checksum := range selected {
+		i := slices.Index(urls, checksum.URL)
+		if i < 0 {
+			urls = append(urls, checksum.URL)
+			algorithms = append(algorithms, nil)
+			i = len(urls) - 1
+		}
+		if !slices.Contains(algorithms[i], checksum.Algorithm()) {
+			algorithms[i] = append(algorithms[i], checksum.Algorithm())
+		}
+	}
+
+	hashes, fetchErr := fetch.HashURLs(urls, algorithms, options)
+	hashOf := func(checksum yml.Checksum) string {
+		return hashes[slices.Index(urls, checksum.URL)][checksum.Algorithm()]
+	}
+
+	if options.Check {
+		checkHashes(selected, hashOf, fetchErr)
+		return
+	}
+
+	written := 0
+	failed := []error{fetchErr}
+	for _, checksum := range selected {
+		hash := hashOf(checksum)
+		if hash == "" {
+			continue
+		}
+		if err := doc.Set(checksum, hash); err != nil {
+			failed = append(failed, err)
+			continue
+		}
+		written++
+		printer.PrintSuccess("Set " + checksum.Field + " of " + checksum.String() + " to " + checksum.Format(hash))
+	}
+
+	if written > 0 {
+		if err := doc.Save(); err != nil {
+			printer.ExitError(err.Error())
+		}
+		printer.PrintSuccess(fmt.Sprintf("Wrote %d hashes to %s", written, options.PathToFile))
+	}
+
+	if err := errors.Join(failed...); err != nil {
+		printer.ExitError(err.Error())
+	}
+}
+
+// checkHashes compares the computed hashes with the config, exiting with an error listing every mismatch
+func checkHashes(checksums []yml.Checksum, hashOf func(checksum yml.Checksum) string, fetchErr error) {
+	var problems []string
+	for _, checksum := range checksums {
+		hash := hashOf(checksum)
+		switch {
+		case hash == "":
+			// Reported through fetchErr
+		case !checksum.IsSet():
+			problems = append(problems, fmt.Sprintf("%s has no %s, the url serves %s", checksum, checksum.Field, checksum.Format(hash)))
+		case !checksum.Matches(hash):
+			problems = append(problems, fmt.Sprintf("%s has %s %s but the url serves %s", checksum, checksum.Field, checksum.Value, checksum.Format(hash)))
+		default:
+			printer.PrintSuccess("Matches " + checksum.String())
+		}
+	}
+
+	if fetchErr != nil {
+		problems = append(problems, fetchErr.Error())
+	}
+
+	if len(problems) > 0 {
+		printer.ExitError(fmt.Sprintf("%d problems found:\n  %s", len(problems), strings.Join(problems, "\n  ")))
+	}
+
+	printer.PrintSuccess("Every hash matches")
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+)
+
+// Example config written by init
+const exampleConfig = `# Binaries binman downloads into bin/<name>/<platform>/<arch>
+#
+# platforms: linux, darwin, windows
+# architectures: arm, arm64, ia32, loong64, mips, mipsel, ppc64, riscv64, s390x, x64
+binaries:
+  - name: ripgrep
+
+    # platform -> architecture -> download URL
+    urls:
+      linux:
+        x64: https://github.com/BurntSushi/ripgrep/releases/download/15.1.0/ripgrep-15.1.0-x86_64-unknown-linux-musl.tar.gz
+      windows:
+        x64: https://github.com/BurntSushi/ripgrep/releases/download/15.1.0/ripgrep-15.1.0-x86_64-pc-windows-gnu.zip
+
+    # platform -> architecture -> SHA256 of the download, use integrity for sha512:<hex> or blake3:<hex>
+    sha256:
+      linux:
+        x64: 1c9297be4a084eea7ecaedf93eb03d058d6faae29bbc57ecdaf5063921491599
+      windows:
+        x64: 0bf217086ecb1392070020810b888bd405cb1dd5f088c16c45d9de1e5ea6b638
+
+    # platform -> architecture -> regex of the files to keep in bin
+    patterns:
+      linux:
+        x64: "^rg$"
+      windows:
+        x64: "^rg\\.exe$"
+`
+
+// Writes an example binman.yml into the path
+func Init(options *args.Options) {
+	if _, err := os.Stat(options.PathToFile); err == nil && !options.Force {
+		printer.ExitError("Config file already exists: " + options.PathToFile + ", pass --force to overwrite it")
+	}
+
+	if err := os.WriteFile(options.PathToFile, []byte(exampleConfig), 0644); err != nil {
+		printer.ExitError("Failed to write config file: " + err.Error())
+	}
+
+	printer.PrintSuccess("Created " + options.PathToFile)
+}
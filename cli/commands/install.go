@@ -0,0 +1,237 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/cleaner"
+	"github.com/UmbrellaCrow612/binman/cli/extractor"
+	"github.com/UmbrellaCrow612/binman/cli/fetch"
+	"github.com/UmbrellaCrow612/binman/cli/lock"
+	"github.com/UmbrellaCrow612/binman/cli/pattern"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+	"github.com/UmbrellaCrow612/binman/cli/stage"
+	"github.com/UmbrellaCrow612/binman/cli/state"
+	"github.com/UmbrellaCrow612/binman/cli/yml"
+)
+
+// Downloads, verifies and installs every target whose config changed since the last install,
+// then records the result in binman.lock. With --frozen the lock must already match the config.
+func Install(options *args.Options) {
+	config := yml.Parse(options)
+
+	previous, err := lock.Load(options.Path)
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+	if options.Frozen {
+		if previous == nil {
+			printer.ExitError("--frozen requires " + lock.Path(options.Path) + ", run binman install without it first")
+		}
+		if diffs := previous.Diff(config, config.Targets(options)); len(diffs) > 0 {
+			printer.ExitError("binman.yml does not match " + lock.FileName + ":\n  " + strings.Join(diffs, "\n  "))
+		}
+	}
+
+	st, err := state.Load(options.Path)
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+	if options.Force {
+		st = &state.State{Targets: map[string]*state.Entry{}}
+	}
+
+	if err := cleaner.CleanStart(options); err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	if err := cleaner.RemoveStale(config, st, options); err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	var pending []shared.Target
+	var frozenDiffs []string
+	for _, target := range config.Targets(options) {
+		if st.UpToDate(target, target.Fingerprint(options), options.Path) {
+			if options.Frozen {
+				frozenDiffs = append(frozenDiffs, diffInstalled(target, previous, options)...)
+			}
+			printer.PrintSuccess("Up to date " + target.String())
+			continue
+		}
+		pending = append(pending, target)
+	}
+
+	if len(frozenDiffs) > 0 {
+		printer.ExitError("installed files do not match " + lock.FileName + ":\n  " + strings.Join(frozenDiffs, "\n  "))
+	}
+
+	if len(pending) == 0 {
+		if err := st.Save(options.Path); err != nil {
+			printer.ExitError(err.Error())
+		}
+		saveLock(config, st, previous, options)
+		cleaner.CleanEnd(options)
+		printer.PrintSuccess("Everything is up to date")
+		return
+	}
+
+	if err := fetch.ResolveChecksums(pending, options); err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	// Hashes read from checksum files are only known now
+	if options.Frozen {
+		if diffs := previous.Diff(config, pending); len(diffs) > 0 {
+			printer.ExitError("binman.yml does not match " + lock.FileName + ":\n  " + strings.Join(diffs, "\n  "))
+		}
+	}
+
+	downloads, err := fetch.FetchTargets(pending, options)
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	if options.NoClean {
+		printer.PrintSuccess("No clean enabled skipping clean")
+	}
+
+	var failed []error
+	for i, target := range pending {
+		var locked *lock.Target
+		if options.Frozen {
+			locked, _ = previous.Get(target)
+		}
+
+		files, err := installTarget(downloads[i], options, locked)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", target, err))
+			continue
+		}
+		st.Set(target, target.Fingerprint(options), resolvedURL(downloads[i], st, previous), downloads[i].Size, files)
+		printer.PrintSuccess("Installed " + target.String())
+	}
+
+	if err := st.Save(options.Path); err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	saveLock(config, st, previous, options)
+
+	cleaner.CleanEnd(options)
+
+	if len(failed) > 0 {
+		printer.ExitError(fmt.Sprintf("%d of %d installs failed, previous installs were kept:\n%v", len(failed), len(pending), errors.Join(failed...)))
+	}
+}
+
+// installTarget extracts the download of a target and builds its bin folder in a staging folder,
+// swapping it into place once copying and pattern cleaning succeeded, so a failure keeps the previous install.
+// When locked is set the staged files must match it exactly.
+func installTarget(dl fetch.Download, options *args.Options, locked *lock.Target) ([]state.File, error) {
+	target := dl.Target
+
+	if err := extractor.ExtractTarget(target, dl.Path, options); err != nil {
+		return nil, err
+	}
+
+	stg, err := stage.New(target, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := extractor.CopyToBin(target, options, stg.Dir); err != nil {
+		stg.Discard()
+		return nil, err
+	}
+
+	// A preserved layout keeps everything, the pattern only finds the executable
+	if !options.NoClean && target.Binary.LAYOUT != shared.LayoutPreserve {
+		if err := pattern.CleanWithPattern(target, stg.Dir); err != nil {
+			stg.Discard()
+			return nil, err
+		}
+	}
+
+	if err := pattern.MarkExecutable(target, stg.Dir); err != nil {
+		stg.Discard()
+		return nil, err
+	}
+
+	if err := pattern.CheckArch(target, stg.Dir, target.ArchCheck(options)); err != nil {
+		stg.Discard()
+		return nil, err
+	}
+
+	files, err := state.Scan(stg.Dir)
+	if err != nil {
+		stg.Discard()
+		return nil, fmt.Errorf("failed to record installed files: %w", err)
+	}
+
+	if locked != nil {
+		if diffs := locked.DiffFiles(files); len(diffs) > 0 {
+			stg.Discard()
+			return nil, fmt.Errorf("installed files do not match %s:\n  %s", lock.FileName, strings.Join(diffs, "\n  "))
+		}
+	}
+
+	if err := stg.Commit(); err != nil {
+		stg.Discard()
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// diffInstalled rescans the installed files of an up to date target and lists how they differ from the lock
+func diffInstalled(target shared.Target, previous *lock.Lock, options *args.Options) []string {
+	locked, ok := previous.Get(target)
+	if !ok {
+		return []string{target.String() + " is not in the lock file"}
+	}
+
+	files, err := state.Scan(filepath.Join(options.Path, "bin", target.Binary.NAME, target.Platform, target.Arch))
+	if err != nil {
+		return []string{fmt.Sprintf("%s: failed to scan installed files: %v", target, err)}
+	}
+
+	diffs := locked.DiffFiles(files)
+	for i, diff := range diffs {
+		diffs[i] = target.String() + ": " + diff
+	}
+	return diffs
+}
+
+// resolvedURL returns the URL a download was served from. Archives taken from the cache or a seed
+// directory were not downloaded, so the URL recorded when the same archive was last installed is kept.
+func resolvedURL(dl fetch.Download, st *state.State, previous *lock.Lock) string {
+	if dl.ResolvedURL != "" {
+		return dl.ResolvedURL
+	}
+
+	hash := dl.Target.Integrity()
+	if entry, ok := st.Get(dl.Target); ok && entry.Integrity == hash && entry.ResolvedURL != "" {
+		return entry.ResolvedURL
+	}
+	if locked, ok := previous.Get(dl.Target); ok && locked.Integrity == hash && locked.ResolvedURL != "" {
+		return locked.ResolvedURL
+	}
+
+	return dl.Target.URL()
+}
+
+// saveLock writes binman.lock from the state, unless --frozen is set as the lock is then only read
+func saveLock(config *shared.Config, st *state.State, previous *lock.Lock, options *args.Options) {
+	if options.Frozen {
+		return
+	}
+
+	if err := lock.Build(config, st, previous).Save(options.Path); err != nil {
+		printer.ExitError("failed to write " + lock.FileName + ": " + err.Error())
+	}
+}
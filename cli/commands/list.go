@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/state"
+	"github.com/UmbrellaCrow612/binman/cli/yml"
+)
+
+// Prints every configured binary with the install status of each of its targets
+func List(options *args.Options) {
+	config := yml.Parse(options)
+
+	st, err := state.Load(options.Path)
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	for i := range config.Binaries {
+		bin := &config.Binaries[i]
+		fmt.Println(bin.NAME)
+
+		for _, target := range bin.Targets(options) {
+			status := "not installed"
+			if entry, ok := st.Get(target); ok {
+				if st.UpToDate(target, target.Fingerprint(options), options.Path) {
+					status = fmt.Sprintf("installed (%d files)", len(entry.Files))
+				} else {
+					status = "outdated"
+				}
+			}
+
+			fmt.Printf("  %-24s %-20s %s\n", target.Platform+"/"+target.Arch, status, target.URL())
+		}
+	}
+}
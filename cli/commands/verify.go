@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/cache"
+	"github.com/UmbrellaCrow612/binman/cli/fetch"
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+	"github.com/UmbrellaCrow612/binman/cli/lock"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+	"github.com/UmbrellaCrow612/binman/cli/state"
+	"github.com/UmbrellaCrow612/binman/cli/yml"
+)
+
+// Re-hashes the installed files and cached archives of every target against the config
+// and the hashes recorded at install time, exiting with an error listing every mismatch.
+// Checksum files are only downloaded with --resolve-checksums, otherwise targets hashed
+// through one are checked against the hash in the lock or state file.
+func Verify(options *args.Options) {
+	config := yml.Parse(options)
+
+	targets := config.Targets(options)
+
+	if options.ResolveChecksums {
+		if err := fetch.ResolveChecksums(targets, options); err != nil {
+			printer.ExitError(err.Error())
+		}
+	}
+
+	st, err := state.Load(options.Path)
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	locked, err := lock.Load(options.Path)
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	var problems []string
+
+	for _, target := range targets {
+		var targetProblems []string
+		expected, err := target.Digest()
+		if !options.ResolveChecksums && target.FromChecksumFile() {
+			expected, err = recordedDigest(target, st, locked)
+		}
+		if err != nil {
+			targetProblems = append(targetProblems, err.Error())
+		} else if path, ok := cache.Lookup(expected); ok {
+			actual, err := integrity.HashFile(path, expected.Algorithm)
+			if err != nil {
+				targetProblems = append(targetProblems, "failed to hash cached archive "+path+": "+err.Error())
+			} else if actual != expected.Hex {
+				targetProblems = append(targetProblems, fmt.Sprintf("cached archive %s has %s %s", path, expected.Algorithm, actual))
+			}
+		}
+
+		entry, ok := st.Get(target)
+		if !ok {
+			targetProblems = append(targetProblems, "not installed")
+		} else {
+			want, source := target.Integrity(), "the config expects"
+			if want == "" && err == nil {
+				want, source = expected.String(), "the lock has"
+			}
+
+			if entry.Integrity != want {
+				targetProblems = append(targetProblems, fmt.Sprintf("installed from %s but %s %s", entry.Integrity, source, want))
+			} else if entry.Fingerprint != target.Fingerprint(options) {
+				targetProblems = append(targetProblems, "installed with a different config, run binman install")
+			}
+
+			dir := filepath.Join(options.Path, "bin", target.Binary.NAME, target.Platform, target.Arch)
+			targetProblems = append(targetProblems, verifyFiles(dir, entry.Files)...)
+		}
+
+		if len(targetProblems) == 0 {
+			printer.PrintSuccess("Verified " + target.String())
+			continue
+		}
+
+		for _, problem := range targetProblems {
+			problems = append(problems, "  "+target.String()+": "+problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		printer.ExitError(fmt.Sprintf("Verification failed with %d problems:\n%s", len(problems), strings.Join(problems, "\n")))
+	}
+
+	printer.PrintSuccess(fmt.Sprintf("Verified %d targets", len(targets)))
+}
+
+// recordedDigest returns the hash a target whose checksum file is not downloaded was locked
+// or installed with, preferring the lock file
+func recordedDigest(target shared.Target, st *state.State, locked *lock.Lock) (integrity.Digest, error) {
+	if entry, ok := locked.Get(target); ok && entry.Integrity != "" {
+		return integrity.Parse(entry.Integrity)
+	}
+	if entry, ok := st.Get(target); ok && entry.Integrity != "" {
+		return integrity.Parse(entry.Integrity)
+	}
+	return integrity.Digest{}, fmt.Errorf("no hash recorded for %s, run binman install or pass --resolve-checksums", target)
+}
+
+// verifyFiles compares the files in dir with the ones recorded when they were installed
+func verifyFiles(dir string, recorded []state.File) []string {
+	var problems []string
+
+	actual, err := state.Scan(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return []string{"failed to read " + dir + ": " + err.Error()}
+	}
+
+	found := map[string]state.File{}
+	for _, file := range actual {
+		found[file.Path] = file
+	}
+
+	for _, file := range recorded {
+		current, ok := found[file.Path]
+		if !ok {
+			problems = append(problems, "missing file "+file.Path)
+			continue
+		}
+		if current.SHA256 != file.SHA256 {
+			problems = append(problems, fmt.Sprintf("file %s has sha256 %s, expected %s", file.Path, current.SHA256, file.SHA256))
+		}
+		delete(found, file.Path)
+	}
+
+	for _, file := range actual {
+		if _, ok := found[file.Path]; ok {
+			problems = append(problems, "unexpected file "+file.Path)
+		}
+	}
+
+	return problems
+}
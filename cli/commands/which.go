@@ -0,0 +1,20 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/resolve"
+)
+
+// Prints the path of the installed executable of options.BinaryName, for this machine
+// unless another platform or architecture was asked for
+func Which(options *args.Options) {
+	path, err := resolve.Find(options.Path, options.BinaryName, options.ResolvePlatform, options.ResolveArch)
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	fmt.Println(path)
+}
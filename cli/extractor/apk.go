@@ -0,0 +1,31 @@
+package extractor
+
+import (
+	"strings"
+)
+
+// extractApk extracts the files of an Alpine .apk package into destDir. An .apk is the signature,
+// the control data and the files as three gzip streams of one tar, so it reads like a .tar.gz.
+func extractApk(path, destDir string, lim *limiter) error {
+	file, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// The gzip reader continues into the next stream by itself
+	gzr, err := gzipReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	return extractTarStream(gzr, destDir, lim, isApkMetadata)
+}
+
+// isApkMetadata reports whether a tar entry of an .apk belongs to its signature or control data
+// such as .PKGINFO, .SIGN.RSA.<key>.pub or .post-install rather than to the installed files
+func isApkMetadata(name string) bool {
+	name = strings.TrimPrefix(name, "./")
+	return strings.HasPrefix(name, ".") && !strings.Contains(name, "/")
+}
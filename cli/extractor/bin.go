@@ -3,189 +3,207 @@ package extractor
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
+	"sort"
 	"strings"
 
 	"github.com/UmbrellaCrow612/binman/cli/args"
 	"github.com/UmbrellaCrow612/binman/cli/shared"
 )
 
-// Flattens downloads and cops files over to bin
-func CopyToBin(bin *shared.Binary, options *args.Options) error {
-	baseDownloadDir := filepath.Join(options.Path, "downloads", bin.NAME)
-	binDir := filepath.Join(options.Path, "bin", bin.NAME)
+// Copies the extracted download of a target into binDir, normally the staging folder of the target's bin.
+// strip_components and subdir of the binary select which files are copied, its layout decides
+// whether they keep their folders or are flattened into binDir.
+func CopyToBin(target shared.Target, options *args.Options, binDir string) error {
+	finalDownloadDir := filepath.Join(options.Path, "downloads", target.Binary.NAME, target.Platform, target.Arch)
+	if _, err := os.Stat(finalDownloadDir); os.IsNotExist(err) {
+		return fmt.Errorf("download folder %s not found", finalDownloadDir)
+	}
 
-	if _, err := os.Stat(baseDownloadDir); os.IsNotExist(err) {
-		return fmt.Errorf("download folder %s not found", baseDownloadDir)
+	files, err := selectFiles(target.Binary, finalDownloadDir)
+	if err != nil {
+		return err
 	}
 
-	for platform, archAndUrlMap := range bin.URLS {
-		if len(options.SpecificPlatformBuilds) > 0 && !slices.Contains(options.SpecificPlatformBuilds, platform) {
-			continue
-		}
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return fmt.Errorf("failed to create bin directory %s: %w", binDir, err)
+	}
 
-		for arch := range archAndUrlMap {
-			if len(options.SpecificArchBuilds) > 0 && !slices.Contains(options.SpecificArchBuilds, arch) {
-				continue
-			}
+	if target.Binary.LAYOUT == shared.LayoutPreserve {
+		return copyPreserved(files, binDir)
+	}
+	return copyFlattened(files, binDir)
+}
 
-			finalDownloadDir := filepath.Join(baseDownloadDir, platform, arch)
-			if _, err := os.Stat(finalDownloadDir); os.IsNotExist(err) {
-				return fmt.Errorf("download folder %s not found", finalDownloadDir)
-			}
+// Represents an extracted file selected for the bin folder
+type selectedFile struct {
+	// Path of the extracted file
+	src string
 
-			if err := removeArchives(finalDownloadDir); err != nil {
-				return err
-			}
-			if err := flattenFolder(finalDownloadDir); err != nil {
-				return err
-			}
-			if err := removeEmptyDirs(finalDownloadDir); err != nil {
-				return err
-			}
+	// Path relative to the bin folder using forward slashes, after strip_components and subdir
+	rel string
+}
 
-			finalBinDir := filepath.Join(binDir, platform, arch)
-			if err := os.MkdirAll(finalBinDir, 0755); err != nil {
-				return fmt.Errorf("failed to create bin directory %s: %w", finalBinDir, err)
-			}
+// selectFiles lists the files under root in lexical order, dropping the first strip_components
+// folders of every path and keeping only the ones inside the folder matching subdir
+func selectFiles(bin *shared.Binary, root string) ([]selectedFile, error) {
+	var files []selectedFile
 
-			files, err := os.ReadDir(finalDownloadDir)
-			if err != nil {
-				return fmt.Errorf("failed to read files in %s: %w", finalDownloadDir, err)
-			}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
 
-			for _, file := range files {
-				srcPath := filepath.Join(finalDownloadDir, file.Name())
-				dstPath := filepath.Join(finalBinDir, file.Name())
-
-				counter := 1
-				for {
-					if _, err := os.Stat(dstPath); os.IsNotExist(err) {
-						break
-					}
-					ext := filepath.Ext(file.Name())
-					name := file.Name()[0 : len(file.Name())-len(ext)]
-					dstPath = filepath.Join(finalBinDir, fmt.Sprintf("%s_%d%s", name, counter, ext))
-					counter++
-				}
-
-				if err := copyFile(srcPath, dstPath); err != nil {
-					return fmt.Errorf("failed to copy file %s to %s: %w", srcPath, dstPath, err)
-				}
-			}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
 		}
-	}
 
-	return nil
-}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) <= bin.STRIP_COMPONENTS {
+			return nil
+		}
 
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	srcFile, err := os.Open(src)
+		files = append(files, selectedFile{src: path, rel: strings.Join(parts[bin.STRIP_COMPONENTS:], "/")})
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to read files in %s: %w", root, err)
 	}
-	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return err
+	if bin.SUBDIR == "" {
+		return files, nil
 	}
-	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
+	dir, err := matchSubdir(bin, files)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Copy permissions
-	info, err := srcFile.Stat()
-	if err == nil {
-		os.Chmod(dst, info.Mode())
+	var selected []selectedFile
+	for _, file := range files {
+		if rel, ok := strings.CutPrefix(file.rel, dir+"/"); ok {
+			selected = append(selected, selectedFile{src: file.src, rel: rel})
+		}
 	}
-
-	return nil
+	return selected, nil
 }
 
-// removeArchives deletes all archive files in the directory and subdirectories
-func removeArchives(root string) error {
-	archiveExts := []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tar.xz"}
-	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// matchSubdir returns the single folder matching the subdir glob of the binary, i.e "ripgrep-*/"
+func matchSubdir(bin *shared.Binary, files []selectedFile) (string, error) {
+	pattern := strings.TrimSuffix(bin.SUBDIR, "/")
+
+	var matches []string
+	for _, file := range files {
+		dir := path.Dir(file.rel)
+		for dir != "." {
+			if ok, _ := path.Match(pattern, dir); ok && !slices.Contains(matches, dir) {
+				matches = append(matches, dir)
+			}
+			dir = path.Dir(dir)
 		}
+	}
 
-		if info.IsDir() {
-			return nil
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("subdir %s matches no folder in the archive", bin.SUBDIR)
+	case 1:
+		return matches[0], nil
+	default:
+		sort.Strings(matches)
+		return "", fmt.Errorf("subdir %s matches more than one folder in the archive: %s", bin.SUBDIR, strings.Join(matches, ", "))
+	}
+}
+
+// copyPreserved copies the files into binDir keeping the folders they are in
+func copyPreserved(files []selectedFile, binDir string) error {
+	for _, file := range files {
+		dstPath := filepath.Join(binDir, filepath.FromSlash(file.rel))
+
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(dstPath), err)
 		}
 
-		lowerName := strings.ToLower(info.Name())
-		for _, ext := range archiveExts {
-			if strings.HasSuffix(lowerName, ext) {
-				if err := os.Remove(path); err != nil {
-					return fmt.Errorf("failed to remove archive %s: %w", path, err)
-				}
-				break
-			}
+		if err := copyFile(file.src, dstPath); err != nil {
+			return fmt.Errorf("failed to copy file %s to %s: %w", file.src, dstPath, err)
 		}
+	}
 
-		return nil
-	})
+	return nil
 }
 
-// flattenFolder moves all files from subdirectories to the root
-func flattenFolder(root string) error {
-	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// copyFlattened copies the files straight into binDir, skipping archives left inside the download.
+// Files already at the top keep their name, the others are renamed name_1.ext, name_2.ext
+// when their name is taken, in lexical order of their path.
+func copyFlattened(files []selectedFile, binDir string) error {
+	var top, nested []selectedFile
+	for _, file := range files {
+		if isArchive(path.Base(file.rel)) {
+			continue
 		}
-
-		if path == root || info.IsDir() {
-			return nil
+		if strings.Contains(file.rel, "/") {
+			nested = append(nested, file)
+		} else {
+			top = append(top, file)
 		}
+	}
 
-		destPath := filepath.Join(root, info.Name())
+	for _, file := range append(top, nested...) {
+		fileName := path.Base(file.rel)
+		dstPath := filepath.Join(binDir, fileName)
 
-		// Handle duplicates
 		counter := 1
 		for {
-			if _, err := os.Stat(destPath); os.IsNotExist(err) {
+			if _, err := os.Stat(dstPath); os.IsNotExist(err) {
 				break
 			}
-			ext := filepath.Ext(info.Name())
-			name := info.Name()[0 : len(info.Name())-len(ext)]
-			destPath = filepath.Join(root, fmt.Sprintf("%s_%d%s", name, counter, ext))
+			ext := filepath.Ext(fileName)
+			name := fileName[0 : len(fileName)-len(ext)]
+			dstPath = filepath.Join(binDir, fmt.Sprintf("%s_%d%s", name, counter, ext))
 			counter++
 		}
 
-		return os.Rename(path, destPath)
-	})
+		if err := copyFile(file.src, dstPath); err != nil {
+			return fmt.Errorf("failed to copy file %s to %s: %w", file.src, dstPath, err)
+		}
+	}
+
+	return nil
 }
 
-// removeEmptyDirs deletes empty directories recursively
-func removeEmptyDirs(root string) error {
-	entries, err := os.ReadDir(root)
+// copyFile copies a file from src to dst
+func copyFile(src, dst string) error {
+	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
 	}
+	defer srcFile.Close()
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			subDir := filepath.Join(root, entry.Name())
-			if err := removeEmptyDirs(subDir); err != nil {
-				return err
-			}
-		}
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
 	}
+	defer dstFile.Close()
 
-	entries, err = os.ReadDir(root)
+	_, err = io.Copy(dstFile, srcFile)
+	if err != nil {
+		return err
+	}
+
+	// Copy permissions
+	info, err := srcFile.Stat()
 	if err != nil {
 		return err
 	}
-	if len(entries) == 0 {
-		return os.Remove(root)
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", dst, err)
 	}
 
 	return nil
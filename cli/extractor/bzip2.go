@@ -0,0 +1,12 @@
+package extractor
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"io"
+)
+
+// bzip2Reader decompresses a .bz2 stream
+func bzip2Reader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(bzip2.NewReader(bufio.NewReader(r))), nil
+}
@@ -0,0 +1,175 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+)
+
+const (
+	// Magic of the "newc" cpio format used by rpm payloads, without and with checksums
+	cpioNewcMagic = "070701"
+	cpioCrcMagic  = "070702"
+
+	// Size of the header in front of every cpio entry, 6 bytes of magic and 13 fields of 8 hex digits
+	cpioHeaderSize = 110
+
+	// Name of the entry marking the end of the archive
+	cpioTrailer = "TRAILER!!!"
+
+	// Longest symlink target read from an entry
+	cpioMaxLinkSize = 4096
+
+	// Longest entry name read, including its terminating NUL, like PATH_MAX
+	cpioMaxNameSize = 4096
+)
+
+// File type bits of a cpio entry mode
+const (
+	cpioTypeMask    = 0170000
+	cpioTypeDir     = 0040000
+	cpioTypeReg     = 0100000
+	cpioTypeSymlink = 0120000
+)
+
+// Represents a cpio entry that is a hardlink, resolved once the entry holding the data was read
+type cpioHardlink struct {
+	path  string
+	name  string
+	inode string
+	mode  os.FileMode
+}
+
+// extractCpio extracts a "newc" cpio stream into destDir with the same rules as extractTarStream.
+// Entries sharing an inode are hardlinks and only the last of them carries the data,
+// so the others are copied from it once the whole archive was read.
+func extractCpio(r io.Reader, destDir string, lim *limiter) error {
+	var links []archiveLink
+	var hardlinks []cpioHardlink
+	inodes := map[string]string{}
+
+	header := make([]byte, cpioHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return fmt.Errorf("error reading cpio: %w", err)
+		}
+
+		magic := string(header[:6])
+		if magic != cpioNewcMagic && magic != cpioCrcMagic {
+			return fmt.Errorf("unsupported cpio format %q, expected newc", magic)
+		}
+
+		var fields [13]int64
+		for i := range fields {
+			value, err := strconv.ParseUint(string(header[6+i*8:14+i*8]), 16, 32)
+			if err != nil {
+				return fmt.Errorf("invalid cpio header: %w", err)
+			}
+			fields[i] = int64(value)
+		}
+		inode, mode, nlink, size, nameSize := fields[0], fields[1], fields[4], fields[6], fields[11]
+
+		// The size comes from the archive, so it is checked before anything is allocated for it
+		if nameSize < 1 || nameSize > cpioMaxNameSize {
+			return fmt.Errorf("invalid cpio header: name of %d bytes", nameSize)
+		}
+
+		nameData := make([]byte, nameSize)
+		if _, err := io.ReadFull(r, nameData); err != nil {
+			return fmt.Errorf("error reading cpio: %w", err)
+		}
+		name := strings.TrimRight(string(nameData), "\x00")
+
+		// The name and the data are each padded to a multiple of 4 bytes
+		if err := skipBytes(r, pad4(cpioHeaderSize+nameSize)); err != nil {
+			return err
+		}
+
+		// Headers count towards the limits too, so an archive of empty entries cannot run unchecked
+		if err := lim.add(name, cpioHeaderSize+nameSize+pad4(cpioHeaderSize+nameSize)); err != nil {
+			return err
+		}
+
+		if name == cpioTrailer {
+			break
+		}
+
+		targetPath, err := safeJoin(destDir, name)
+		if err != nil {
+			return err
+		}
+
+		if err := lim.entry(name); err != nil {
+			return err
+		}
+
+		perm := os.FileMode(mode).Perm()
+		data := &io.LimitedReader{R: r, N: size}
+
+		switch mode & cpioTypeMask {
+		case cpioTypeDir:
+			if err := os.MkdirAll(targetPath, perm|0700); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+		case cpioTypeReg:
+			key := fmt.Sprintf("%d:%d:%d", fields[7], fields[8], inode)
+			if nlink > 1 && size == 0 {
+				hardlinks = append(hardlinks, cpioHardlink{path: targetPath, name: name, inode: key, mode: perm})
+				break
+			}
+			if err := writeFile(targetPath, lim.reader(name, data), perm); err != nil {
+				return err
+			}
+			if nlink > 1 {
+				inodes[key] = name
+			}
+		case cpioTypeSymlink:
+			if size > cpioMaxLinkSize {
+				return fmt.Errorf("symlink %s in archive is too long", name)
+			}
+			linkname, err := io.ReadAll(data)
+			if err != nil {
+				return fmt.Errorf("error reading cpio: %w", err)
+			}
+			links = append(links, symlink(destDir, targetPath, name, string(linkname)))
+		default:
+			printer.PrintWarning(fmt.Sprintf("Skipping unsupported cpio entry %s of mode %o", name, mode))
+		}
+
+		// Skip whatever of the data was not read
+		if err := skipBytes(r, data.N+pad4(size)); err != nil {
+			return err
+		}
+	}
+
+	for _, link := range hardlinks {
+		if linkname, ok := inodes[link.inode]; ok {
+			links = append(links, hardlink(destDir, link.path, link.name, linkname))
+			continue
+		}
+
+		// Every entry of an empty file has no data
+		if err := writeFile(link.path, strings.NewReader(""), link.mode); err != nil {
+			return err
+		}
+	}
+
+	return materializeLinks(destDir, links, lim)
+}
+
+// pad4 returns how many bytes pad n to a multiple of 4
+func pad4(n int64) int64 {
+	return (4 - n%4) % 4
+}
+
+// skipBytes reads past n bytes of r
+func skipBytes(r io.Reader, n int64) error {
+	if _, err := io.CopyN(io.Discard, r, n); err != nil {
+		return fmt.Errorf("error reading cpio: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package extractor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Magic of ar archives, the container of .deb packages
+const arMagic = "!<arch>\n"
+
+// Size of the header in front of every ar member
+const arHeaderSize = 60
+
+// extractDeb extracts the files of a .deb package into destDir. A .deb is an ar archive
+// whose data.tar member, compressed with any of the supported formats, holds the files.
+func extractDeb(path, destDir string, lim *limiter) error {
+	file, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != arMagic {
+		return fmt.Errorf("not an ar archive")
+	}
+
+	header := make([]byte, arHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return fmt.Errorf("package has no data.tar member")
+		} else if err != nil {
+			return fmt.Errorf("error reading ar: %w", err)
+		}
+
+		// GNU ar ends names with a slash
+		name := strings.TrimSuffix(strings.TrimSpace(string(header[:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil || size < 0 {
+			return fmt.Errorf("invalid size of ar member %s", name)
+		}
+
+		if strings.HasPrefix(name, "data.tar") {
+			payload, err := decompressStream(bufio.NewReader(io.LimitReader(r, size)))
+			if err != nil {
+				return err
+			}
+			defer payload.Close()
+
+			return extractTarStream(payload, destDir, lim, nil)
+		}
+
+		// Members are padded to an even size
+		if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil && err != io.EOF {
+			return fmt.Errorf("error reading ar: %w", err)
+		}
+	}
+}
@@ -1,89 +1,311 @@
 package extractor
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
-	"io/fs"
+	"io"
 	"os"
 	"path/filepath"
-	"slices"
+	"strings"
 
 	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
 )
 
-var supportedArchiveFormats = []string{".zip", ".tar", ".gz", ".xz"}
+// Represents a download format, recognised by the first bytes of the file
+// or failing that by the suffix of its file name
+type archiveFormat struct {
+	// Name used by the format field of binman.yml, see shared.ArchiveFormats
+	name string
 
-// Gets all folders which are archive
-func GetAllArchiveFiles(basePath string) ([]string, error) {
-	downloadPath := filepath.Join(basePath, "downloads")
-	var foundPaths []string
+	// Lower case suffixes, including compound ones such as .tar.gz
+	suffixes []string
 
-	_, err := os.Stat(downloadPath)
-	if err != nil {
-		return nil, err
+	// Reports whether the start of a file is this format
+	magic func(header []byte) bool
+
+	// Set when the magic is shared with another format, so the suffix must match too
+	needsSuffix bool
+
+	// Wraps a compressed stream in a decompressor, nil for the other formats.
+	// The decompressed stream is either a tar or a single file.
+	decompress func(r io.Reader) (io.ReadCloser, error)
+
+	// Extracts an archive file into a folder, nil for compressed streams
+	extract func(path, destDir string, lim *limiter) error
+}
+
+// Supported compressed streams, holding a tar or a single file
+var compressedFormats = []archiveFormat{
+	{name: "tar.gz", suffixes: []string{".tar.gz", ".tgz", ".gz"}, magic: prefix("\x1f\x8b"), decompress: gzipReader},
+	{name: "tar.xz", suffixes: []string{".tar.xz", ".txz", ".xz"}, magic: prefix("\xfd7zXZ\x00"), decompress: xzReader},
+	{name: "tar.bz2", suffixes: []string{".tar.bz2", ".tbz", ".tbz2", ".tb2", ".bz2"}, magic: prefix("BZh"), decompress: bzip2Reader},
+	{name: "tar.zst", suffixes: []string{".tar.zst", ".tzst", ".zst"}, magic: prefix("\x28\xb5\x2f\xfd"), decompress: zstdReader},
+}
+
+// Supported download formats. Packages come before the compressed streams as an .apk is gzip too.
+var archiveFormats = append([]archiveFormat{
+	{name: "zip", suffixes: []string{".zip"}, magic: prefix("PK\x03\x04", "PK\x05\x06"), extract: unZip},
+	{name: "tar", suffixes: []string{".tar"}, magic: isTar, extract: extractTar},
+	{name: "deb", suffixes: []string{".deb"}, magic: prefix(arMagic + "debian-binary"), extract: extractDeb},
+	{name: "rpm", suffixes: []string{".rpm"}, magic: prefix(rpmMagic), extract: extractRpm},
+	{name: "apk", suffixes: []string{".apk"}, magic: prefix("\x1f\x8b"), needsSuffix: true, extract: extractApk},
+}, compressedFormats...)
+
+// Format name of downloads installed as they are
+const rawFormat = "raw"
+
+// How much of a file is read to sniff its format, enough to reach the tar magic
+const headerSize = 512
+
+// prefix returns a magic check matching any of the given byte prefixes
+func prefix(prefixes ...string) func(header []byte) bool {
+	return func(header []byte) bool {
+		for _, p := range prefixes {
+			if bytes.HasPrefix(header, []byte(p)) {
+				return true
+			}
+		}
+		return false
 	}
+}
+
+// isTar reports whether header starts a ustar or GNU tar archive
+func isTar(header []byte) bool {
+	return len(header) >= 262 && string(header[257:262]) == "ustar"
+}
 
-	err = filepath.WalkDir(downloadPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
+// formatByName returns the archive format named in binman.yml
+func formatByName(name string) (archiveFormat, bool) {
+	for _, format := range archiveFormats {
+		if format.name == name {
+			return format, true
 		}
+	}
+	return archiveFormat{}, false
+}
+
+// formatOf returns the archive format of a file name and the suffix it matched, matching the longest suffix
+func formatOf(name string) (archiveFormat, string, bool) {
+	name = strings.ToLower(name)
 
-		if d.IsDir() {
-			return nil
+	var found archiveFormat
+	matched := ""
+	for _, format := range archiveFormats {
+		for _, suffix := range format.suffixes {
+			if strings.HasSuffix(name, suffix) && len(suffix) > len(matched) {
+				found = format
+				matched = suffix
+			}
 		}
+	}
+
+	return found, matched, matched != ""
+}
+
+// isArchive reports whether a file name is one of the supported archive formats
+func isArchive(name string) bool {
+	_, _, ok := formatOf(name)
+	return ok
+}
 
-		extension := filepath.Ext(d.Name())
-		if extension == "" {
-			return nil
+// sniffFormat returns the archive format of a file from its first bytes.
+// Files without a recognised header fall back to their suffix, as old tar archives have no magic.
+func sniffFormat(path string) (archiveFormat, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return archiveFormat{}, false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return archiveFormat{}, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	header = header[:n]
+
+	byName, _, named := formatOf(filepath.Base(path))
+
+	for _, format := range archiveFormats {
+		if format.magic(header) && (!format.needsSuffix || named && byName.name == format.name) {
+			return format, true, nil
 		}
+	}
+
+	return byName, named, nil
+}
 
-		if !slices.Contains(supportedArchiveFormats, extension) {
-			return nil
+// decompressStream decompresses a stream by its first bytes, returning it as it is when it is not compressed
+func decompressStream(r *bufio.Reader) (io.ReadCloser, error) {
+	header, _ := r.Peek(headerSize)
+
+	for _, format := range compressedFormats {
+		if format.magic(header) {
+			return format.decompress(r)
 		}
+	}
 
-		fullPath, err := filepath.Abs(path)
-		if err != nil {
-			return err
+	return io.NopCloser(r), nil
+}
+
+// ExtractTarget extracts the downloaded file of a target into the folder it is in and removes it.
+// The format comes from the binary's format field when set, otherwise it is sniffed from the file.
+// A file that is not an archive, or a compressed stream that does not hold a tar, is installed
+// as a single executable named by the binary's rename field.
+// Extraction fails as soon as it goes over the limits of the target.
+func ExtractTarget(target shared.Target, path string, options *args.Options) error {
+	lim, err := newLimiter(target.Limits(options), path)
+	if err != nil {
+		return err
+	}
+
+	var format archiveFormat
+	ok := false
+	if target.Binary.FORMAT != rawFormat {
+		format, ok = formatByName(target.Binary.FORMAT)
+		if !ok {
+			format, ok, err = sniffFormat(path)
+			if err != nil {
+				return err
+			}
 		}
+	}
 
-		foundPaths = append(foundPaths, fullPath)
-		return nil
-	})
+	destDir := filepath.Dir(path)
 
+	// An entry with the name of the archive replaces it, which must then not be removed below
+	archive, err := os.Stat(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return foundPaths, nil
+	switch {
+	case !ok:
+		err = installRaw(target, path, destDir)
+	case format.decompress != nil:
+		err = extractCompressed(target, path, destDir, format, lim)
+	default:
+		err = format.extract(path, destDir, lim)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s as %s: %w", path, formatName(format, ok), err)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	if current, err := os.Stat(path); err == nil && os.SameFile(archive, current) {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove archive %s: %w", path, err)
+		}
+	}
+
+	return extractNested(destDir, target.Binary.NESTED_DEPTH, lim)
+}
+
+// formatName returns the name of a format for messages
+func formatName(format archiveFormat, ok bool) string {
+	if !ok {
+		return rawFormat
+	}
+	return format.name
 }
 
-// Extract all downloads
-func Extract(options *args.Options) error {
-	archPaths, err := GetAllArchiveFiles(options.Path)
+// extractCompressed decompresses a file and extracts it as a tar when it holds one,
+// otherwise the decompressed content is the binary itself
+func extractCompressed(target shared.Target, path, destDir string, format archiveFormat, lim *limiter) error {
+	file, err := openArchive(path)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 
-	for _, path := range archPaths {
-		ext := filepath.Ext(path)
-		var extractError error
-
-		switch ext {
-		case ".zip":
-			extractError = unZip(path)
-		case ".tar":
-			extractError = extractTar(path)
-		case ".gz":
-			extractError = extractTarGz(path)
-		case ".xz":
-			extractError = extractTarXzExternal(path)
-		default:
-			extractError = fmt.Errorf("Unsupported  format %s ", ext)
-		}
+	dr, err := format.decompress(file)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
 
-		if extractError != nil {
-			return extractError
-		}
+	br := bufio.NewReaderSize(lim.reader(filepath.Base(path), dr), headerSize)
+	header, err := br.Peek(headerSize)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decompress: %w", err)
 	}
 
+	if isTar(header) {
+		return extractTarStream(br, destDir, lim, nil)
+	}
+
+	name := filepath.Base(path)
+	if _, suffix, ok := formatOf(name); ok {
+		name = name[:len(name)-len(suffix)]
+	}
+	return writeExecutable(target, br, destDir, name)
+}
+
+// installRaw makes a downloaded file that is not an archive the executable of the target
+func installRaw(target shared.Target, path, destDir string) error {
+	file, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := writeExecutable(target, file, destDir, filepath.Base(path)); err != nil {
+		return err
+	}
+
+	// writeExecutable already replaced the download when it kept its name
+	if name := executableName(target, filepath.Base(path)); name != filepath.Base(path) {
+		file.Close()
+		return os.Remove(path)
+	}
 	return nil
 }
+
+// executableName returns the name a single file binary is installed as, the binary's rename
+// for the target when there is one and otherwise the name it was downloaded with
+func executableName(target shared.Target, name string) string {
+	if rename := target.Binary.RENAME[target.Platform][target.Arch]; rename != "" {
+		return rename
+	}
+	if name == "" {
+		return target.Binary.NAME
+	}
+	return name
+}
+
+// writeExecutable writes r into destDir as the executable of the target, marked executable for
+// linux and darwin. It writes a new file rather than changing the download in place
+// since the download can be a hard link into the download cache.
+func writeExecutable(target shared.Target, r io.Reader, destDir, name string) error {
+	name = executableName(target, name)
+
+	mode := os.FileMode(0755)
+	if target.Platform == "windows" {
+		mode = 0644
+	}
+
+	tmp, err := os.CreateTemp(destDir, ".binman-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to make %s executable: %w", name, err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(destDir, name))
+}
@@ -0,0 +1,16 @@
+package extractor
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// gzipReader decompresses a .gz stream, i.e a .tar.gz or .tgz archive or a single gzipped binary
+func gzipReader(r io.Reader) (io.ReadCloser, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	return gzr, nil
+}
@@ -0,0 +1,79 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+	"github.com/UmbrellaCrow612/binman/cli/units"
+)
+
+// The ratio limit only applies once this much was extracted, so tiny archives of
+// very compressible files do not trip it
+const ratioFloor = 1 << 20
+
+// limiter counts what an archive extracts and fails once it goes over the limits of the target
+type limiter struct {
+	limits shared.Limits
+
+	// Size of the archive file, for the ratio limit
+	archiveSize int64
+
+	written int64
+	entries int
+}
+
+// newLimiter creates a limiter for the archive at path
+func newLimiter(limits shared.Limits, path string) (*limiter, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("file does not exist: %w", err)
+	}
+	return &limiter{limits: limits, archiveSize: info.Size()}, nil
+}
+
+// entry counts an archive entry
+func (l *limiter) entry(name string) error {
+	l.entries++
+	if l.limits.Entries > 0 && l.entries > l.limits.Entries {
+		return fmt.Errorf("archive has more than %d entries, the limit was reached at %s (limits.entries)", l.limits.Entries, name)
+	}
+	return nil
+}
+
+// add counts n more bytes extracted for the entry name
+func (l *limiter) add(name string, n int64) error {
+	l.written += n
+
+	if l.limits.ExtractedSize > 0 && l.written > int64(l.limits.ExtractedSize) {
+		return fmt.Errorf("%s takes the archive over %s extracted (limits.extracted_size)", name, units.FormatBytes(int64(l.limits.ExtractedSize)))
+	}
+
+	if l.limits.Ratio > 0 && l.written > ratioFloor && float64(l.written) > l.limits.Ratio*float64(l.archiveSize) {
+		return fmt.Errorf("%s takes the archive over %g times its size of %s extracted (limits.ratio)", name, l.limits.Ratio, units.FormatBytes(l.archiveSize))
+	}
+
+	return nil
+}
+
+// reader wraps the content of the entry name so every byte read is counted
+func (l *limiter) reader(name string, r io.Reader) io.Reader {
+	return &limitedReader{r: r, name: name, limiter: l}
+}
+
+type limitedReader struct {
+	r       io.Reader
+	name    string
+	limiter *limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if limitErr := r.limiter.add(r.name, int64(n)); limitErr != nil {
+			return n, limitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,174 @@
+package extractor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+)
+
+// Represents a symlink or hardlink found in an archive, written as a copy of its target
+type archiveLink struct {
+	// Where the copy is written
+	path string
+
+	// What is copied, empty when the link points outside the extraction root
+	target string
+
+	// Entry name and link target as written in the archive, for messages
+	name     string
+	linkname string
+}
+
+// safeJoin joins an archive entry name onto root, failing when the entry would end up outside of it
+func safeJoin(root, name string) (string, error) {
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("illegal path %s in archive", name)
+	}
+
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if !within(root, path) {
+		return "", fmt.Errorf("illegal path %s in archive", name)
+	}
+	return path, nil
+}
+
+// within reports whether path is root or inside it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && (rel == "." || filepath.IsLocal(rel))
+}
+
+// symlink creates the link of a symlink entry, whose target is relative to the folder the link is in
+func symlink(root, path, name, linkname string) archiveLink {
+	link := archiveLink{path: path, name: name, linkname: linkname}
+
+	if filepath.IsAbs(linkname) || strings.HasPrefix(linkname, "/") {
+		return link
+	}
+
+	target := filepath.Join(filepath.Dir(path), filepath.FromSlash(linkname))
+	if within(root, target) && target != path {
+		link.target = target
+	}
+	return link
+}
+
+// hardlink creates the link of a hardlink entry, whose target is an entry name of the same archive
+func hardlink(root, path, name, linkname string) archiveLink {
+	link := archiveLink{path: path, name: name, linkname: linkname}
+
+	if target, err := safeJoin(root, linkname); err == nil && target != path {
+		link.target = target
+	}
+	return link
+}
+
+// materializeLinks writes a copy of the target of every link. Links can point at other links
+// so they are copied in rounds until no more can be resolved. Links pointing outside root,
+// at nothing or in a cycle are skipped with a warning.
+func materializeLinks(root string, links []archiveLink, lim *limiter) error {
+	var pending []archiveLink
+	for _, link := range links {
+		if link.target == "" {
+			printer.PrintWarning(fmt.Sprintf("Skipping link %s -> %s, it points outside the archive", link.name, link.linkname))
+			continue
+		}
+		pending = append(pending, link)
+	}
+
+	for len(pending) > 0 {
+		var next []archiveLink
+		for i, link := range pending {
+			if dependsOnPending(i, pending) {
+				next = append(next, link)
+				continue
+			}
+
+			info, err := os.Stat(link.target)
+			if err != nil {
+				next = append(next, link)
+				continue
+			}
+
+			if info.IsDir() && within(link.target, link.path) {
+				printer.PrintWarning(fmt.Sprintf("Skipping link %s -> %s, it points at a folder containing itself", link.name, link.linkname))
+				continue
+			}
+
+			if err := os.MkdirAll(filepath.Dir(link.path), 0755); err != nil {
+				return fmt.Errorf("failed to create parent directory: %w", err)
+			}
+			if err := copyTree(link.target, link.path, info, link.name, lim); err != nil {
+				return fmt.Errorf("failed to copy link %s -> %s: %w", link.name, link.linkname, err)
+			}
+		}
+
+		if len(next) == len(pending) {
+			for _, link := range next {
+				printer.PrintWarning(fmt.Sprintf("Skipping link %s -> %s, its target is missing from the archive or part of a cycle", link.name, link.linkname))
+			}
+			break
+		}
+		pending = next
+	}
+
+	return nil
+}
+
+// dependsOnPending reports whether the target of pending[i] is, is inside or contains
+// another link that has not been copied yet, so copying it now would miss files
+func dependsOnPending(i int, pending []archiveLink) bool {
+	for j, other := range pending {
+		if j == i {
+			continue
+		}
+		if within(other.path, pending[i].target) || within(pending[i].target, other.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyTree copies a file, or a folder with everything in it, from src to dst.
+// Copies count towards the limits of the archive as a link to a large folder could otherwise act as a bomb.
+func copyTree(src, dst string, info fs.FileInfo, name string, lim *limiter) error {
+	if !info.IsDir() {
+		if err := lim.add(name, info.Size()); err != nil {
+			return err
+		}
+		os.Remove(dst)
+		return copyFile(src, dst)
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := lim.entry(name); err != nil {
+			return err
+		}
+		if err := lim.add(name, info.Size()); err != nil {
+			return err
+		}
+		return copyFile(path, target)
+	})
+}
@@ -0,0 +1,120 @@
+package extractor
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// extractNested extracts the archives found in destDir next to where they are and removes them,
+// repeating for the archives that came out of them up to depth levels. The limiter keeps counting
+// across levels. Compressed files that do not hold a tar, such as gzipped man pages, are left as they are.
+func extractNested(destDir string, depth int, lim *limiter) error {
+	for level := 1; level <= depth; level++ {
+		var archives []string
+		err := filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() && isArchive(d.Name()) {
+				archives = append(archives, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read files in %s: %w", destDir, err)
+		}
+
+		extracted := 0
+		for _, path := range archives {
+			ok, err := extractNestedArchive(path, lim)
+			if err != nil {
+				return err
+			}
+			if ok {
+				extracted++
+			}
+		}
+
+		if extracted == 0 {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// extractNestedArchive extracts an archive into the folder it is in and removes it,
+// reporting false when it turned out not to be an archive holding files
+func extractNestedArchive(path string, lim *limiter) (bool, error) {
+	format, ok, err := sniffFormat(path)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if format.decompress != nil {
+		if ok, err := holdsTar(path, format); err != nil || !ok {
+			return false, err
+		}
+	}
+
+	// Moved out of the way first so an entry of the same name does not get removed with it
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".binman-nested-*")
+	if err != nil {
+		return false, fmt.Errorf("failed to create file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := os.Rename(path, tmp.Name()); err != nil {
+		return false, fmt.Errorf("failed to move %s: %w", path, err)
+	}
+
+	if format.decompress != nil {
+		err = extractCompressedTar(tmp.Name(), filepath.Dir(path), format, lim)
+	} else {
+		err = format.extract(tmp.Name(), filepath.Dir(path), lim)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to extract nested archive %s as %s: %w", filepath.Base(path), format.name, err)
+	}
+
+	return true, nil
+}
+
+// holdsTar reports whether a compressed file decompresses into a tar
+func holdsTar(path string, format archiveFormat) (bool, error) {
+	file, err := openArchive(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	dr, err := format.decompress(file)
+	if err != nil {
+		return false, err
+	}
+	defer dr.Close()
+
+	header, _ := bufio.NewReaderSize(dr, headerSize).Peek(headerSize)
+	return isTar(header), nil
+}
+
+// extractCompressedTar extracts a compressed tar into destDir
+func extractCompressedTar(path, destDir string, format archiveFormat, lim *limiter) error {
+	file, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dr, err := format.decompress(file)
+	if err != nil {
+		return err
+	}
+	defer dr.Close()
+
+	return extractTarStream(dr, destDir, lim, nil)
+}
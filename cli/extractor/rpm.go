@@ -0,0 +1,79 @@
+package extractor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// Magic at the start of the lead of an .rpm package
+	rpmMagic = "\xed\xab\xee\xdb"
+
+	// Size of the lead, an obsolete fixed size header in front of the real headers
+	rpmLeadSize = 96
+
+	// Magic at the start of the signature and main headers
+	rpmHeaderMagic = "\x8e\xad\xe8\x01"
+)
+
+// extractRpm extracts the files of an .rpm package into destDir. After the lead come the
+// signature and main headers, which are skipped, then a compressed cpio archive holding the files.
+func extractRpm(path, destDir string, lim *limiter) error {
+	file, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	if _, err := io.CopyN(io.Discard, r, rpmLeadSize); err != nil {
+		return fmt.Errorf("error reading rpm lead: %w", err)
+	}
+
+	size, err := skipRpmHeader(r)
+	if err != nil {
+		return fmt.Errorf("error reading rpm signature: %w", err)
+	}
+
+	// Only the signature header is padded to a multiple of 8 bytes
+	if _, err := io.CopyN(io.Discard, r, (8-size%8)%8); err != nil {
+		return fmt.Errorf("error reading rpm signature: %w", err)
+	}
+
+	if _, err := skipRpmHeader(r); err != nil {
+		return fmt.Errorf("error reading rpm header: %w", err)
+	}
+
+	payload, err := decompressStream(r)
+	if err != nil {
+		return err
+	}
+	defer payload.Close()
+
+	return extractCpio(payload, destDir, lim)
+}
+
+// skipRpmHeader reads past a header structure and returns its size. A header is a 16 byte intro
+// with the number of index entries and the size of the data, then 16 bytes per index entry and the data.
+func skipRpmHeader(r io.Reader) (int64, error) {
+	intro := make([]byte, 16)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return 0, err
+	}
+	if string(intro[:4]) != rpmHeaderMagic {
+		return 0, fmt.Errorf("bad header magic")
+	}
+
+	count := int64(binary.BigEndian.Uint32(intro[8:12]))
+	dataSize := int64(binary.BigEndian.Uint32(intro[12:16]))
+	length := count*16 + dataSize
+
+	if _, err := io.CopyN(io.Discard, r, length); err != nil {
+		return 0, err
+	}
+
+	return int64(len(intro)) + length, nil
+}
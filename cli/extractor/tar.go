@@ -2,40 +2,52 @@ package extractor
 
 import (
 	"archive/tar"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/printer"
 )
 
-func extractTar(tarPath string) error {
-	// Validate file exists
-	info, err := os.Stat(tarPath)
+// extractTar extracts a .tar file into destDir
+func extractTar(tarPath, destDir string, lim *limiter) error {
+	file, err := openArchive(tarPath)
 	if err != nil {
-		return fmt.Errorf("file does not exist: %w", err)
-	}
-	if info.IsDir() {
-		return fmt.Errorf("path is a directory, not a file")
+		return err
 	}
+	defer file.Close()
 
-	// Validate .tar extension
-	if strings.ToLower(filepath.Ext(tarPath)) != ".tar" {
-		return fmt.Errorf("file is not a .tar archive")
-	}
+	return extractTarStream(file, destDir, lim, nil)
+}
 
-	// Open the tar file
-	file, err := os.Open(tarPath)
+// openArchive opens an archive file after checking it exists and is not a directory
+func openArchive(path string) (*os.File, error) {
+	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("failed to open tar file: %w", err)
+		return nil, fmt.Errorf("file does not exist: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("path is a directory, not a file")
 	}
-	defer file.Close()
 
-	// Create a tar reader
-	tr := tar.NewReader(file)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return file, nil
+}
 
-	// Extract files to the same directory as tar
-	destDir := filepath.Dir(tarPath)
+// extractTarStream extracts an uncompressed tar stream into destDir.
+// Every tar based format decompresses into this so they all behave the same.
+// Entries escaping destDir fail the extraction. Symlinks and hardlinks are not created as links,
+// once every entry is written they are replaced by copies of what they point to inside destDir.
+// The limiter counts entries and the bytes written, including the copies of links.
+// Entries for which skip returns true are left out, skip may be nil.
+func extractTarStream(r io.Reader, destDir string, lim *limiter, skip func(name string) bool) error {
+	tr := tar.NewReader(r)
+	var links []archiveLink
 
 	for {
 		header, err := tr.Next()
@@ -46,33 +58,69 @@ func extractTar(tarPath string) error {
 			return fmt.Errorf("error reading tar: %w", err)
 		}
 
-		targetPath := filepath.Join(destDir, header.Name)
+		if skip != nil && skip(header.Name) {
+			continue
+		}
+
+		targetPath, err := safeJoin(destDir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := lim.entry(header.Name); err != nil {
+			return err
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)); err != nil {
+			if err := os.MkdirAll(targetPath, header.FileInfo().Mode().Perm()|0700); err != nil {
 				return fmt.Errorf("failed to create directory: %w", err)
 			}
 		case tar.TypeReg:
-			// Ensure parent directory exists
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-				return fmt.Errorf("failed to create parent directory: %w", err)
+			if err := writeFile(targetPath, lim.reader(header.Name, tr), header.FileInfo().Mode().Perm()); err != nil {
+				return err
 			}
-
-			outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
-			if err != nil {
-				return fmt.Errorf("failed to create file: %w", err)
-			}
-
-			if _, err := io.Copy(outFile, tr); err != nil {
-				outFile.Close()
-				return fmt.Errorf("failed to write file: %w", err)
-			}
-			outFile.Close()
+		case tar.TypeSymlink:
+			links = append(links, symlink(destDir, targetPath, header.Name, header.Linkname))
+		case tar.TypeLink:
+			links = append(links, hardlink(destDir, targetPath, header.Name, header.Linkname))
+		case tar.TypeXGlobalHeader:
+			// Metadata for the following entries, nothing to extract
 		default:
-			fmt.Printf("Skipping unsupported type: %c in %s\n", header.Typeflag, header.Name)
+			printer.PrintWarning(fmt.Sprintf("Skipping unsupported tar entry %s of type %q", header.Name, header.Typeflag))
 		}
 	}
 
+	return materializeLinks(destDir, links, lim)
+}
+
+// writeFile writes an archive entry to path, creating its parent folders
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	if err := removeExisting(path); err != nil {
+		return err
+	}
+
+	outFile, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	if _, err := io.Copy(outFile, r); err != nil {
+		outFile.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return outFile.Close()
+}
+
+// removeExisting removes a file at path before an entry is written there, so the entry gets a new
+// file instead of writing through a link to another one, such as the archive being extracted
+func removeExisting(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
 	return nil
 }
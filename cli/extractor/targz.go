@@ -1,87 +0,0 @@
-package extractor
-
-import (
-	"archive/tar"
-	"compress/gzip"
-	"fmt"
-	"io"
-	"os"
-	"path/filepath"
-	"strings"
-)
-
-// ExtractTarGz extracts a .tar.gz file to the same directory as the archive
-func extractTarGz(tarGzPath string) error {
-	// Validate file exists
-	info, err := os.Stat(tarGzPath)
-	if err != nil {
-		return fmt.Errorf("file does not exist: %w", err)
-	}
-	if info.IsDir() {
-		return fmt.Errorf("path is a directory, not a file")
-	}
-
-	// Validate .tar.gz extension
-	if !strings.HasSuffix(strings.ToLower(tarGzPath), ".tar.gz") {
-		return fmt.Errorf("file is not a .tar.gz archive")
-	}
-
-	// Open the tar.gz file
-	file, err := os.Open(tarGzPath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-
-	// Create a gzip reader
-	gzr, err := gzip.NewReader(file)
-	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
-	}
-	defer gzr.Close()
-
-	// Create a tar reader
-	tr := tar.NewReader(gzr)
-
-	// Extract files to the same directory as tar.gz
-	destDir := filepath.Dir(tarGzPath)
-
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break // end of archive
-		}
-		if err != nil {
-			return fmt.Errorf("error reading tar: %w", err)
-		}
-
-		targetPath := filepath.Join(destDir, header.Name)
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("failed to create directory: %w", err)
-			}
-		case tar.TypeReg:
-			// Ensure parent directory exists
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-				return fmt.Errorf("failed to create parent directory: %w", err)
-			}
-
-			outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
-			if err != nil {
-				return fmt.Errorf("failed to create file: %w", err)
-			}
-
-			if _, err := io.Copy(outFile, tr); err != nil {
-				outFile.Close()
-				return fmt.Errorf("failed to write file: %w", err)
-			}
-			outFile.Close()
-		default:
-			fmt.Printf("Skipping unsupported type: %c in %s\n", header.Typeflag, header.Name)
-		}
-	}
-
-	return nil
-}
@@ -0,0 +1,18 @@
+package extractor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+// xzReader decompresses a .xz stream with a pure Go decoder, so no system tar or xz-utils is needed
+func xzReader(r io.Reader) (io.ReadCloser, error) {
+	xzr, err := xz.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create xz reader: %w", err)
+	}
+	return io.NopCloser(xzr), nil
+}
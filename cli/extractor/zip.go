@@ -6,11 +6,18 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
-// Unzips a zip file into a folder in the same directory
-func unZip(path string) error {
+// Creator systems of a zip entry, from the upper byte of its "version made by" field
+const (
+	zipCreatorUnix  = 3
+	zipCreatorMacOS = 19
+)
+
+// Unzips a zip file into destDir, counting what it writes with the limiter.
+// Permissions and symlinks are taken from the external attributes of entries made on unix,
+// symlinks are written as copies of their target like in tar archives.
+func unZip(path, destDir string, lim *limiter) error {
 	info, err := os.Stat(path)
 	if err != nil {
 		return err
@@ -20,33 +27,40 @@ func unZip(path string) error {
 		return errors.New("path is a directory, not a zip file")
 	}
 
-	if !strings.HasSuffix(strings.ToLower(path), ".zip") {
-		return errors.New("file is not a zip")
-	}
-
 	r, err := zip.OpenReader(path)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
 
-	destDir := strings.TrimSuffix(path, filepath.Ext(path))
-
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return err
 	}
 
+	var links []archiveLink
 	for _, f := range r.File {
-		fPath := filepath.Join(destDir, f.Name)
+		if err := lim.entry(f.Name); err != nil {
+			return err
+		}
 
-		if !strings.HasPrefix(fPath, filepath.Clean(destDir)+string(os.PathSeparator)) {
-			return errors.New("illegal file path in zip")
+		fPath, err := safeJoin(destDir, f.Name)
+		if err != nil {
+			return err
 		}
 
 		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(fPath, f.Mode()); err != nil {
+			if err := os.MkdirAll(fPath, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if isUnixZip(f) && f.Mode()&os.ModeSymlink != 0 {
+			linkname, err := readZipLink(f)
+			if err != nil {
 				return err
 			}
+			links = append(links, symlink(destDir, fPath, f.Name, linkname))
 			continue
 		}
 
@@ -54,7 +68,12 @@ func unZip(path string) error {
 			return err
 		}
 
-		outFile, err := os.OpenFile(fPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+		if err := removeExisting(fPath); err != nil {
+			return err
+		}
+
+		mode := zipFileMode(f)
+		outFile, err := os.OpenFile(fPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
 		if err != nil {
 			return err
 		}
@@ -65,7 +84,7 @@ func unZip(path string) error {
 			return err
 		}
 
-		_, err = io.Copy(outFile, rc)
+		_, err = io.Copy(outFile, lim.reader(f.Name, rc))
 
 		outFile.Close()
 		rc.Close()
@@ -73,7 +92,44 @@ func unZip(path string) error {
 		if err != nil {
 			return err
 		}
+
+		// OpenFile only applies the mode to new files and is subject to the umask
+		if err := os.Chmod(fPath, mode); err != nil {
+			return err
+		}
+	}
+
+	return materializeLinks(destDir, links, lim)
+}
+
+// isUnixZip reports whether an entry was made on unix, so its external attributes hold unix permissions
+func isUnixZip(f *zip.File) bool {
+	creator := f.CreatorVersion >> 8
+	return (creator == zipCreatorUnix || creator == zipCreatorMacOS) && f.ExternalAttrs>>16 != 0
+}
+
+// zipFileMode returns the permissions of a file entry. Entries made on Windows only carry
+// DOS attributes, which say nothing about permissions, so they get 0644 and
+// the pattern decides what is executable.
+func zipFileMode(f *zip.File) os.FileMode {
+	if isUnixZip(f) {
+		return f.Mode().Perm()
+	}
+	return 0644
+}
+
+// readZipLink returns the target of a symlink entry, which is stored as its content
+func readZipLink(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
 	}
+	defer rc.Close()
 
-	return nil
+	// Link targets are paths, anything longer is not a real link
+	target, err := io.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return "", err
+	}
+	return string(target), nil
 }
@@ -0,0 +1,198 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/cache"
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+)
+
+// ResolveChecksums reads the hash of every given target without a sha256 or integrity entry from the
+// checksum file at its binary's checksum_url, see shared.Binary.SetChecksum. Only the targets about to be
+// installed or verified are passed, so checksum files of binaries that are up to date are never fetched.
+// Each checksum file is read once, from the seed directory, then from the download cache when it is pinned
+// with checksum_integrity, and is downloaded otherwise.
+func ResolveChecksums(targets []shared.Target, opts *args.Options) error {
+	var binaries []*shared.Binary
+	needed := map[*shared.Binary][]shared.Target{}
+	for _, target := range targets {
+		if !target.FromChecksumFile() {
+			continue
+		}
+		if _, ok := needed[target.Binary]; !ok {
+			binaries = append(binaries, target.Binary)
+		}
+		needed[target.Binary] = append(needed[target.Binary], target)
+	}
+
+	if len(binaries) == 0 {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "binman-checksums-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := newDownloader(opts)
+
+	errs := runOrdered(len(binaries), opts.Concurrency, func(i int, log *printer.Buffer) error {
+		bin := binaries[i]
+
+		data, err := fetchChecksumFile(bin, filepath.Join(dir, fmt.Sprintf("%d-checksums", i)), d, opts, log)
+		if err != nil {
+			return err
+		}
+
+		file, err := integrity.ParseChecksumFile(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", bin.CHECKSUM_URL, err)
+		}
+
+		for _, target := range needed[bin] {
+			digest, err := file.Lookup(fileNameFromURL(target.URL()))
+			if err != nil {
+				return fmt.Errorf("%s/%s: %s: %w", target.Platform, target.Arch, bin.CHECKSUM_URL, err)
+			}
+			bin.SetChecksum(target.Platform, target.Arch, digest)
+		}
+		return nil
+	})
+
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", binaries[i].NAME, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to read %d checksum files:\n%w", len(failed), errors.Join(failed...))
+	}
+
+	return nil
+}
+
+// fetchChecksumFile returns the content of the checksum file of a binary. It is taken from the seed directory
+// when it is there, from the download cache when it is pinned and cached there, and is downloaded into
+// filePath otherwise. Seeded and downloaded files are checked against their checksum_signature,
+// a cached file matches the pinned hash and was only cached after its signature verified.
+func fetchChecksumFile(bin *shared.Binary, filePath string, d *downloader, opts *args.Options, log *printer.Buffer) ([]byte, error) {
+	var pinned *integrity.Digest
+	if bin.CHECKSUM_INTEGRITY != "" {
+		digest, err := integrity.Parse(bin.CHECKSUM_INTEGRITY)
+		if err != nil {
+			return nil, err
+		}
+		pinned = &digest
+	}
+
+	if src, ok := seedChecksumPath(bin, pinned, opts); ok {
+		if err := cache.CopyFile(src, filePath); err != nil {
+			return nil, fmt.Errorf("failed to use seeded %s: %w", src, err)
+		}
+		if pinned != nil {
+			if err := integrity.Verify(filePath, *pinned); err != nil {
+				return nil, fmt.Errorf("seeded %s: %w", src, err)
+			}
+		}
+		if err := checkChecksumSignature(bin, filePath, d, opts, log); err != nil {
+			return nil, err
+		}
+
+		log.PrintSuccess("Using seeded " + bin.CHECKSUM_URL)
+		return os.ReadFile(filePath)
+	}
+
+	if pinned != nil && !opts.NoCache && fetchFromCache(*pinned, filePath, log) {
+		return os.ReadFile(filePath)
+	}
+
+	if opts.Offline {
+		if pinned == nil {
+			return nil, fmt.Errorf("%s is not in the seed directory and cannot be read from the cache without checksum_integrity", bin.CHECKSUM_URL)
+		}
+		return nil, fmt.Errorf("%s is not available offline (%s)", bin.CHECKSUM_URL, pinned)
+	}
+
+	log.PrintSuccess("Fetching " + bin.CHECKSUM_URL)
+
+	verify := func(path string) error {
+		if pinned == nil {
+			return nil
+		}
+		return integrity.Verify(path, *pinned)
+	}
+
+	if _, err := d.download(bin.CHECKSUM_URL, filePath, opts.MaxDownloadSize, verify, log); err != nil {
+		return nil, err
+	}
+
+	if err := checkChecksumSignature(bin, filePath, d, opts, log); err != nil {
+		return nil, err
+	}
+
+	if pinned != nil && !opts.NoCache {
+		if err := cache.Store(filePath, *pinned); err != nil {
+			log.PrintWarning("Failed to cache " + bin.CHECKSUM_URL + ": " + err.Error())
+		}
+	}
+
+	return os.ReadFile(filePath)
+}
+
+// checkChecksumSignature verifies the checksum file at filePath against the checksum_signature of its binary, if any.
+// The signature is read from the seed directory when it is there.
+func checkChecksumSignature(bin *shared.Binary, filePath string, d *downloader, opts *args.Options, log *printer.Buffer) error {
+	if bin.CHECKSUM_SIGNATURE == nil {
+		return nil
+	}
+
+	url := bin.ChecksumSignatureURL()
+	seeded, _ := seedFile(opts, bin.NAME, fileNameFromURL(url))
+	if err := checkSignature(bin.CHECKSUM_SIGNATURE, url, seeded, filePath, d, opts, log); err != nil {
+		return err
+	}
+
+	log.PrintSuccess("Signature (" + bin.CHECKSUM_SIGNATURE.Type + ") verified for " + bin.CHECKSUM_URL)
+	return nil
+}
+
+// seedChecksumPath finds the checksum file of a binary in the seed directory, by file name, under
+// <name>/<file name> and by its pinned hash
+func seedChecksumPath(bin *shared.Binary, pinned *integrity.Digest, opts *args.Options) (string, bool) {
+	if opts.SeedDir == "" {
+		return "", false
+	}
+
+	if src, ok := seedFile(opts, bin.NAME, fileNameFromURL(bin.CHECKSUM_URL)); ok {
+		return src, true
+	}
+
+	if pinned == nil {
+		return "", false
+	}
+	return firstFile([]string{
+		filepath.Join(opts.SeedDir, pinned.Hex),
+		filepath.Join(opts.SeedDir, pinned.Algorithm, pinned.Hex),
+	})
+}
+
+// seedFile finds a file of a binary in the seed directory by its file name, at the top or under <name>/
+func seedFile(opts *args.Options, name, fileName string) (string, bool) {
+	if opts.SeedDir == "" {
+		return "", false
+	}
+
+	return firstFile([]string{
+		filepath.Join(opts.SeedDir, fileName),
+		filepath.Join(opts.SeedDir, name, fileName),
+	})
+}
@@ -0,0 +1,300 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/units"
+)
+
+const (
+	// Delay before the first retry, doubled on every following attempt
+	baseRetryDelay = time.Second
+
+	// Upper bound for the delay between two attempts
+	maxRetryDelay = 30 * time.Second
+)
+
+// downloader fetches files over HTTP with timeouts, retries and resume support.
+// It is safe to share between workers.
+type downloader struct {
+	client      *http.Client
+	retries     int
+	readTimeout time.Duration
+}
+
+// newDownloader creates a downloader using the timeout and retry settings from the options
+func newDownloader(opts *args.Options) *downloader {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   opts.Timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   opts.Concurrency,
+	}
+
+	return &downloader{
+		client:      &http.Client{Transport: transport},
+		retries:     opts.Retries,
+		readTimeout: opts.Timeout,
+	}
+}
+
+// permanentError marks a failure that retrying will not fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// download fetches url into filePath and returns the URL it was served from after redirects.
+// Data is written to filePath.part and resumed with a range request if a previous attempt was
+// cut off. The part file is only renamed to filePath once verify accepts it, so filePath never
+// holds a partial or unverified file. A resumed file that fails verify is fetched once more
+// from the start. A maxSize above 0 fails downloads of more bytes.
+func (d *downloader) download(url, filePath string, maxSize int64, verify func(path string) error, log *printer.Buffer) (string, error) {
+	partPath := filePath + ".part"
+
+	resolvedURL, resumed, err := d.downloadPart(url, partPath, maxSize, log)
+	if err != nil {
+		return "", err
+	}
+
+	err = verify(partPath)
+	if err != nil && resumed {
+		// The bytes kept from an earlier attempt may not belong to the same file
+		log.PrintWarning(fmt.Sprintf("Resumed download of %s failed verification, downloading it again: %v", url, err))
+		os.Remove(partPath)
+
+		resolvedURL, _, err = d.downloadPart(url, partPath, maxSize, log)
+		if err != nil {
+			return "", err
+		}
+		err = verify(partPath)
+	}
+	if err != nil {
+		// A corrupt part file must not be resumed by a later attempt
+		os.Remove(partPath)
+		return "", err
+	}
+
+	if err := os.Rename(partPath, filePath); err != nil {
+		return "", fmt.Errorf("failed to move %s to %s: %w", partPath, filePath, err)
+	}
+
+	return resolvedURL, nil
+}
+
+// downloadPart fetches url into partPath, retrying failed attempts with a growing delay.
+// It reports whether any of the data came from resuming an earlier attempt.
+func (d *downloader) downloadPart(url, partPath string, maxSize int64, log *printer.Buffer) (string, bool, error) {
+	var resolvedURL string
+	var resumed, partial bool
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			delay := retryDelay(attempt)
+			log.PrintWarning(fmt.Sprintf("Retrying %s in %s (attempt %d of %d): %v", url, delay, attempt, d.retries, err))
+			time.Sleep(delay)
+		}
+
+		resolvedURL, partial, err = d.downloadOnce(url, partPath, maxSize, log)
+		resumed = resumed || partial
+		if err == nil {
+			break
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			break
+		}
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return resolvedURL, resumed, nil
+}
+
+// downloadOnce makes a single attempt at fetching url into partPath, continuing from
+// the end of partPath when it already has data and the server supports ranges.
+// It returns the URL the data was served from after redirects and whether it resumed.
+func (d *downloader) downloadOnce(url, partPath string, maxSize int64, log *printer.Buffer) (string, bool, error) {
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, &permanentError{fmt.Errorf("invalid url %s: %w", url, err)}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+		if err != nil || start != offset {
+			// Appending a range that does not continue the part file would corrupt it
+			os.Remove(partPath)
+			return "", false, fmt.Errorf("failed to resume %s from byte %d: unexpected Content-Range %q", url, offset, resp.Header.Get("Content-Range"))
+		}
+		log.PrintSuccess(fmt.Sprintf("Resuming %s from byte %d", url, offset))
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// Server ignored the range or there was nothing to resume, start over
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The part file already holds the whole file, verification decides if it is usable
+		return resp.Request.URL.String(), true, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout:
+		return "", false, fmt.Errorf("failed to fetch %s: status %s", url, resp.Status)
+	default:
+		return "", false, &permanentError{fmt.Errorf("failed to fetch %s: status %s", url, resp.Status)}
+	}
+
+	if flags&os.O_TRUNC != 0 {
+		offset = 0
+	}
+
+	if maxSize > 0 && resp.ContentLength >= 0 && offset+resp.ContentLength > maxSize {
+		os.Remove(partPath)
+		return "", false, &permanentError{tooLarge(url, maxSize)}
+	}
+
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return "", false, &permanentError{fmt.Errorf("failed to create file %s: %w", partPath, err)}
+	}
+	defer out.Close()
+
+	body := newIdleTimeoutReader(resp.Body, d.readTimeout, cancel)
+	defer body.stop()
+
+	// Servers can leave out or lie about the length, so the body itself is capped too
+	var src io.Reader = body
+	if maxSize > 0 {
+		src = io.LimitReader(body, maxSize-offset+1)
+	}
+
+	written, err := io.Copy(out, src)
+	if err != nil {
+		if body.timedOut() {
+			return "", false, fmt.Errorf("failed to read %s: no data received for %s", url, d.readTimeout)
+		}
+		return "", false, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+
+	if maxSize > 0 && offset+written > maxSize {
+		out.Close()
+		os.Remove(partPath)
+		return "", false, &permanentError{tooLarge(url, maxSize)}
+	}
+
+	if err := out.Close(); err != nil {
+		return "", false, &permanentError{fmt.Errorf("failed to write file %s: %w", partPath, err)}
+	}
+
+	return resp.Request.URL.String(), offset > 0, nil
+}
+
+// contentRangeStart returns the first byte position of a "bytes start-end/total" Content-Range header
+func contentRangeStart(header string) (int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, fmt.Errorf("unsupported range unit in %q", header)
+	}
+
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, fmt.Errorf("malformed range %q", header)
+	}
+
+	return strconv.ParseInt(start, 10, 64)
+}
+
+// tooLarge describes a download going over the download size limit
+func tooLarge(url string, maxSize int64) error {
+	return fmt.Errorf("%s is larger than the download limit of %s (limits.download_size)", url, units.FormatBytes(maxSize))
+}
+
+// retryDelay returns the exponential backoff delay with jitter for the given attempt
+func retryDelay(attempt int) time.Duration {
+	delay := min(baseRetryDelay<<(attempt-1), maxRetryDelay)
+	jitter := time.Duration(rand.Int64N(int64(delay) / 4))
+	return delay + jitter
+}
+
+// idleTimeoutReader cancels a request when no data has been read from it for the timeout,
+// as http.Client only offers a timeout for the whole request which large downloads would hit
+type idleTimeoutReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	once    sync.Once
+	expired chan struct{}
+}
+
+func newIdleTimeoutReader(r io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	reader := &idleTimeoutReader{
+		r:       r,
+		timeout: timeout,
+		expired: make(chan struct{}),
+	}
+	reader.timer = time.AfterFunc(timeout, func() {
+		reader.once.Do(func() { close(reader.expired) })
+		cancel()
+	})
+	return reader
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// timedOut reports whether the reader was cancelled for being idle
+func (r *idleTimeoutReader) timedOut() bool {
+	select {
+	case <-r.expired:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *idleTimeoutReader) stop() {
+	r.timer.Stop()
+}
@@ -1,113 +1,190 @@
 package fetch
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
-	"slices"
 	"strings"
 
 	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/cache"
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
 	"github.com/UmbrellaCrow612/binman/cli/printer"
 	"github.com/UmbrellaCrow612/binman/cli/shared"
 )
 
-// Fetches the binary urls into path/downloads
-// downloads all of them into the convention
-// opts.PATH/downloads/ripgrep/linux/x86_64/ripgrep.zip
-func FetchAndStoreBinary(bin *shared.Binary, opts *args.Options) error {
+// Represents an archive fetched into the downloads folder
+type Download struct {
+	Target shared.Target
 
-	// Base dir becomes example downloads/ripgrep
-	baseDir := filepath.Join(opts.Path, "downloads", bin.NAME)
-	if err := os.MkdirAll(baseDir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create download directory: %w", err)
+	// Path of the archive in the downloads folder
+	Path string
+
+	// URL the archive was served from after following redirects,
+	// empty when it came from the cache or the seed directory
+	ResolvedURL string
+
+	// Size of the archive in bytes
+	Size int64
+}
+
+// Fetches the targets into opts.PATH/downloads/<name>/<platform>/<arch>, i.e downloads/ripgrep/linux/x64/ripgrep.zip,
+// running up to opts.Concurrency downloads at the same time with a bounded pool of workers, see runOrdered.
+// A failed download does not stop the others, all failures are returned together once every target
+// has been attempted. The returned downloads are in the same order as the targets.
+func FetchTargets(targets []shared.Target, opts *args.Options) ([]Download, error) {
+	if len(targets) == 0 {
+		return nil, nil
 	}
 
-	for platform, architectureAndUrl := range bin.URLS {
-		if len(opts.SpecificPlatformBuilds) > 0 &&
-			!slices.Contains(opts.SpecificPlatformBuilds, platform) {
-			printer.PrintSuccess("Skipping fetch " + platform)
-			continue
+	if opts.Offline {
+		if err := checkOffline(targets, opts); err != nil {
+			return nil, err
 		}
+	}
+
+	d := newDownloader(opts)
+	downloads := make([]Download, len(targets))
+
+	errs := runOrdered(len(targets), opts.Concurrency, func(i int, log *printer.Buffer) error {
+		var err error
+		downloads[i], err = fetchTarget(targets[i], d, opts, log)
+		return err
+	})
 
-		for architecture, url := range architectureAndUrl {
-			if len(opts.SpecificArchBuilds) > 0 && !slices.Contains(opts.SpecificArchBuilds, architecture) {
-				printer.PrintSuccess("Skipping fetch " + architecture)
-				continue
-			}
-
-			printer.PrintSuccess("Fetching " + url)
-
-			// Example: downloads/ripgrep/linux/x86_64
-			finalDir := filepath.Join(baseDir, platform, architecture)
-			if err := os.MkdirAll(finalDir, os.ModePerm); err != nil {
-				return fmt.Errorf("failed to create download directory: %w", err)
-			}
-
-			resp, err := http.Get(url)
-			if err != nil {
-				return fmt.Errorf("failed to fetch %s: %w", url, err)
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				return fmt.Errorf("failed to fetch %s: status %s", url, resp.Status)
-			}
-
-			// Determine filename from the URL
-			parts := strings.Split(url, "/")
-			fileName := parts[len(parts)-1]
-			filePath := filepath.Join(finalDir, fileName)
-
-			out, err := os.Create(filePath)
-			if err != nil {
-				return fmt.Errorf("failed to create file %s: %w", filePath, err)
-			}
-			defer out.Close()
-
-			if _, err := io.Copy(out, resp.Body); err != nil {
-				return fmt.Errorf("failed to write file %s: %w", filePath, err)
-			}
-
-			expectedSHA, ok := bin.SHA256[platform][architecture]
-			if !ok {
-				return fmt.Errorf("no SHA256 provided for %s/%s", platform, architecture)
-			}
-
-			if err := VerifySHA256(filePath, expectedSHA); err != nil {
-				return err
-			}
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", targets[i], err))
 		}
 	}
 
-	return nil
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("%d of %d downloads failed:\n%w", len(failed), len(targets), errors.Join(failed...))
+	}
+
+	return downloads, nil
 }
 
-// Helper function to check SHA256 of a file
-func VerifySHA256(filePath, expectedSHA string) error {
-	file, err := os.Open(filePath)
+// fetchTarget fetches a single target with fetchArchive and checks its signature when the binary has one.
+// An archive whose signature does not verify is removed from the downloads folder.
+func fetchTarget(target shared.Target, d *downloader, opts *args.Options, log *printer.Buffer) (Download, error) {
+	result, err := fetchArchive(target, d, opts, log)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", filePath, err)
+		return Download{}, err
 	}
-	defer file.Close()
 
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, file); err != nil {
-		return fmt.Errorf("failed to hash file %s: %w", filePath, err)
+	if err := verifySignature(target, result.Path, d, opts, log); err != nil {
+		os.Remove(result.Path)
+		return Download{}, err
 	}
 
-	actualSHA := hex.EncodeToString(hasher.Sum(nil))
-	if actualSHA != expectedSHA {
-		return fmt.Errorf(
-			"SHA256 mismatch for %s: expected %s, got %s",
-			filePath, expectedSHA, actualSHA,
-		)
+	return result, nil
+}
+
+// fetchArchive downloads the archive of a target into
+// opts.PATH/downloads/<name>/<platform>/<arch>, keeping the file only when its hash matches
+func fetchArchive(target shared.Target, d *downloader, opts *args.Options, log *printer.Buffer) (Download, error) {
+	url := target.URL()
+
+	// Example: downloads/ripgrep/linux/x86_64
+	finalDir := filepath.Join(opts.Path, "downloads", target.Binary.NAME, target.Platform, target.Arch)
+	if err := os.MkdirAll(finalDir, os.ModePerm); err != nil {
+		return Download{}, fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	filePath := filepath.Join(finalDir, fileNameFromURL(url))
+	result := Download{Target: target, Path: filePath}
+
+	expected, err := target.Digest()
+	if err != nil {
+		return Download{}, err
+	}
+
+	if fetchFromSeed(target, expected, filePath, opts, log) {
+		log.PrintSuccess("Using seeded " + url + " for " + target.String())
+		return result.withSize()
+	}
+
+	if !opts.NoCache && fetchFromCache(expected, filePath, log) {
+		log.PrintSuccess("Using cached " + url + " for " + target.String())
+		return result.withSize()
+	}
+
+	if opts.Offline {
+		return Download{}, fmt.Errorf("%s is not available offline (%s)", url, expected)
+	}
+
+	log.PrintSuccess("Fetching " + url)
+
+	verify := func(path string) error {
+		return integrity.Verify(path, expected)
 	}
 
-	printer.PrintSuccess("SHA256 verified for " + filePath)
-	return nil
+	resolvedURL, err := d.download(url, filePath, int64(target.Limits(opts).DownloadSize), verify, log)
+	if err != nil {
+		return Download{}, err
+	}
+	result.ResolvedURL = resolvedURL
+
+	log.PrintSuccess(strings.ToUpper(expected.Algorithm) + " verified for " + filePath)
+
+	if !opts.NoCache {
+		if err := cache.Store(filePath, expected); err != nil {
+			log.PrintWarning("Failed to cache " + filePath + ": " + err.Error())
+		}
+	}
+
+	return result.withSize()
+}
+
+// withSize fills in the size of the downloaded archive
+func (dl Download) withSize() (Download, error) {
+	info, err := os.Stat(dl.Path)
+	if err != nil {
+		return Download{}, err
+	}
+	dl.Size = info.Size()
+	return dl, nil
+}
+
+// fetchFromCache places the cached archive with the digest at filePath and reports if it could.
+// A cached archive that no longer matches its hash is dropped from the cache so it gets downloaded again.
+func fetchFromCache(expected integrity.Digest, filePath string, log *printer.Buffer) bool {
+	if _, ok := cache.Lookup(expected); !ok {
+		return false
+	}
+
+	if err := cache.CopyTo(expected, filePath); err != nil {
+		log.PrintWarning("Failed to use cached archive " + expected.String() + ": " + err.Error())
+		return false
+	}
+
+	if err := integrity.Verify(filePath, expected); err != nil {
+		log.PrintWarning("Cached archive " + expected.String() + " is corrupt, removing it")
+		os.Remove(filePath)
+		cache.Remove(expected)
+		return false
+	}
+
+	return true
+}
+
+// fileNameFromURL determines the file name of a download from the last part of its URL path,
+// leaving out any query string or fragment
+func fileNameFromURL(rawURL string) string {
+	name := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		name = u.Path
+	}
+
+	name = path.Base(name)
+	if name == "." || name == ".." || name == "/" || name == "" {
+		// i.e https://example.com/?download=latest, the format is sniffed from the content anyway
+		return "download"
+	}
+	return name
 }
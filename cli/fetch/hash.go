@@ -0,0 +1,80 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/cache"
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+)
+
+// HashURLs downloads every URL into a temporary folder and returns their hashes under the
+// algorithms given for it in the same order, as algorithm -> hex. Downloads run like FetchTargets but nothing is
+// verified, the point is to learn the hash. Hashed archives are added to the download cache so the
+// next install does not fetch them again. A URL that failed has no hashes and its error is part of the returned error.
+func HashURLs(urls []string, algorithms [][]string, opts *args.Options) ([]map[string]string, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	if opts.Offline {
+		return nil, errors.New("hashing downloads every url and cannot run offline")
+	}
+
+	dir, err := os.MkdirTemp("", "binman-hash-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := newDownloader(opts)
+	hashes := make([]map[string]string, len(urls))
+
+	errs := runOrdered(len(urls), opts.Concurrency, func(i int, log *printer.Buffer) error {
+		// Prefixed with the index as different urls can end in the same file name
+		filePath := filepath.Join(dir, strconv.Itoa(i)+"-"+fileNameFromURL(urls[i]))
+
+		log.PrintSuccess("Fetching " + urls[i])
+
+		var sums map[string]string
+		record := func(path string) error {
+			var err error
+			sums, err = integrity.Sums(path, algorithms[i])
+			return err
+		}
+
+		if _, err := d.download(urls[i], filePath, opts.MaxDownloadSize, record, log); err != nil {
+			return err
+		}
+		hashes[i] = sums
+
+		if !opts.NoCache {
+			for algorithm, hex := range sums {
+				if err := cache.Store(filePath, integrity.Digest{Algorithm: algorithm, Hex: hex}); err != nil {
+					log.PrintWarning("Failed to cache " + urls[i] + ": " + err.Error())
+				}
+			}
+		}
+
+		os.Remove(filePath)
+		return nil
+	})
+
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %w", urls[i], err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return hashes, fmt.Errorf("%d of %d downloads failed:\n%w", len(failed), len(urls), errors.Join(failed...))
+	}
+
+	return hashes, nil
+}
@@ -0,0 +1,106 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/cache"
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+)
+
+// checkOffline makes sure every target can be installed without the network,
+// listing every archive that is neither in the cache nor in the seed directory and
+// every signature that is not in the seed directory
+func checkOffline(targets []shared.Target, opts *args.Options) error {
+	var missing []string
+
+	for _, target := range targets {
+		expected, err := target.Digest()
+		if err != nil {
+			return err
+		}
+
+		if target.Binary.SIGNATURE != nil {
+			if _, ok := seedSignaturePath(target, opts); !ok {
+				missing = append(missing, fmt.Sprintf("  %s %s %s %s", target.Binary.NAME, target.Platform, target.Arch, target.SignatureURL()))
+			}
+		}
+
+		if _, ok := seedPath(target, expected, opts); ok {
+			continue
+		}
+
+		if !opts.NoCache {
+			if _, ok := cache.Lookup(expected); ok {
+				continue
+			}
+		}
+
+		missing = append(missing, fmt.Sprintf("  %s %s %s %s", target.Binary.NAME, target.Platform, target.Arch, expected))
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"offline mode: %d files of %d targets are not available locally (name platform arch hash or signature URL):\n%s",
+			len(missing), len(targets), strings.Join(missing, "\n"),
+		)
+	}
+
+	return nil
+}
+
+// seedPath finds the archive of a target in the seed directory. Archives are looked up by
+// hash, by file name and by the downloads convention <name>/<platform>/<arch>/<file name>
+func seedPath(target shared.Target, expected integrity.Digest, opts *args.Options) (string, bool) {
+	if opts.SeedDir == "" {
+		return "", false
+	}
+
+	fileName := fileNameFromURL(target.URL())
+	candidates := []string{
+		filepath.Join(opts.SeedDir, expected.Hex),
+		filepath.Join(opts.SeedDir, expected.Algorithm, expected.Hex),
+		filepath.Join(opts.SeedDir, fileName),
+		filepath.Join(opts.SeedDir, target.Binary.NAME, target.Platform, target.Arch, fileName),
+	}
+
+	return firstFile(candidates)
+}
+
+// firstFile returns the first of the candidates that is a regular file
+func firstFile(candidates []string) (string, bool) {
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate, true
+		}
+	}
+
+	return "", false
+}
+
+// fetchFromSeed places a copy of the archive of a target from the seed directory at filePath and reports if it could.
+// The seed directory is only read, a hard link would let extraction next to the archive write into it.
+func fetchFromSeed(target shared.Target, expected integrity.Digest, filePath string, opts *args.Options, log *printer.Buffer) bool {
+	src, ok := seedPath(target, expected, opts)
+	if !ok {
+		return false
+	}
+
+	if err := cache.CopyFile(src, filePath); err != nil {
+		log.PrintWarning("Failed to use seeded archive " + src + ": " + err.Error())
+		return false
+	}
+
+	if err := integrity.Verify(filePath, expected); err != nil {
+		log.PrintWarning("Seeded archive " + src + " does not match its " + strings.ToUpper(expected.Algorithm) + ", ignoring it")
+		os.Remove(filePath)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,49 @@
+package fetch
+
+import (
+	"sync"
+
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+)
+
+// runOrdered calls job for 0..n-1 on up to concurrency workers and returns the error of each call.
+// Output of each job is buffered and printed in job order so logs read the same
+// no matter which job finishes first.
+func runOrdered(n, concurrency int, job func(i int, log *printer.Buffer) error) []error {
+	workers := max(1, min(concurrency, n))
+
+	logs := make([]printer.Buffer, n)
+	errs := make([]error, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = job(i, &logs[i])
+				close(done[i])
+			}
+		}()
+	}
+
+	go func() {
+		for i := range n {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	for i := range n {
+		<-done[i]
+		logs[i].Flush()
+	}
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,88 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+)
+
+// Largest signature file that is downloaded, they are a few kilobytes at most
+const maxSignatureSize = 64 * 1024
+
+// verifySignature checks the archive of a signed target at filePath against its detached signature,
+// see shared.Signature. Targets of binaries without a signature pass as they are.
+func verifySignature(target shared.Target, filePath string, d *downloader, opts *args.Options, log *printer.Buffer) error {
+	if target.Binary.SIGNATURE == nil {
+		return nil
+	}
+
+	seeded, _ := seedSignaturePath(target, opts)
+	if err := checkSignature(target.Binary.SIGNATURE, target.SignatureURL(), seeded, filePath, d, opts, log); err != nil {
+		return err
+	}
+
+	log.PrintSuccess("Signature (" + target.Binary.SIGNATURE.Type + ") verified for " + filePath)
+	return nil
+}
+
+// checkSignature verifies the file at filePath with the signature at url, read from the seeded path
+// when that is set and otherwise downloaded next to the file and removed again once read
+func checkSignature(sig *shared.Signature, url, seeded, filePath string, d *downloader, opts *args.Options, log *printer.Buffer) error {
+	verifier, err := sig.Verifier()
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if seeded != "" {
+		data, err = os.ReadFile(seeded)
+	} else {
+		data, err = downloadSignature(url, filePath+".binman-sig", d, opts, log)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := verifier.Verify(filePath, data); err != nil {
+		return fmt.Errorf("bad %s signature %s: %w", sig.Type, url, err)
+	}
+
+	return nil
+}
+
+// downloadSignature fetches the signature file at url through sigPath and returns its content
+func downloadSignature(url, sigPath string, d *downloader, opts *args.Options, log *printer.Buffer) ([]byte, error) {
+	if opts.Offline {
+		return nil, fmt.Errorf("signature %s is not available offline, add it to the seed directory", url)
+	}
+
+	log.PrintSuccess("Fetching " + url)
+
+	noVerify := func(path string) error { return nil }
+	if _, err := d.download(url, sigPath, maxSignatureSize, noVerify, log); err != nil {
+		return nil, err
+	}
+	defer os.Remove(sigPath)
+
+	return os.ReadFile(sigPath)
+}
+
+// seedSignaturePath finds the signature of a target in the seed directory, by its file name
+// or by the downloads convention <name>/<platform>/<arch>/<file name>
+func seedSignaturePath(target shared.Target, opts *args.Options) (string, bool) {
+	if opts.SeedDir == "" {
+		return "", false
+	}
+
+	fileName := fileNameFromURL(target.SignatureURL())
+	candidates := []string{
+		filepath.Join(opts.SeedDir, fileName),
+		filepath.Join(opts.SeedDir, target.Binary.NAME, target.Platform, target.Arch, fileName),
+	}
+
+	return firstFile(candidates)
+}
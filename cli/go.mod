@@ -3,12 +3,20 @@ module github.com/UmbrellaCrow612/binman/cli
 go 1.24.6
 
 require (
+	github.com/ProtonMail/go-crypto v1.3.0
 	github.com/fatih/color v1.18.0
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/crypto v0.48.0
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
+	lukechampine.com/blake3 v1.4.1
 )
 
 require (
+	github.com/cloudflare/circl v1.6.1 // indirect
+	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.25.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
 )
@@ -0,0 +1,96 @@
+package integrity
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// GNU coreutils and goreleaser lines, "<hex>  <file>" or "<hex> *<file>" for binary mode
+	gnuLine = regexp.MustCompile(`^([0-9a-fA-F]+) [ *]?(.+)$`)
+
+	// BSD and sha*sum --tag lines, "SHA256 (<file>) = <hex>"
+	bsdLine = regexp.MustCompile(`^([A-Za-z0-9-]+) ?\((.+)\) ?= ?([0-9a-fA-F]+)$`)
+)
+
+// Algorithms of GNU lines, which only tell the algorithm by the length of the hash
+var algorithmsBySize = map[int]string{64: "sha256", 96: "sha384", 128: "sha512"}
+
+// Represents a parsed checksum file such as SHA256SUMS or a goreleaser checksums.txt
+type ChecksumFile struct {
+	// Hashes by file name as written in the file, without a leading ./
+	digests map[string]Digest
+}
+
+// ParseChecksumFile reads a checksum file in the GNU coreutils, BSD or goreleaser format.
+// Blank lines, comments and lines in no known format are skipped.
+func ParseChecksumFile(data []byte) (*ChecksumFile, error) {
+	file := &ChecksumFile{digests: map[string]Digest{}}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var algorithm, name, value string
+		if match := bsdLine.FindStringSubmatch(line); match != nil {
+			algorithm, name, value = strings.ToLower(match[1]), match[2], match[3]
+		} else if match := gnuLine.FindStringSubmatch(line); match != nil {
+			name, value = match[2], match[1]
+			algorithm = algorithmsBySize[len(value)]
+		}
+		if algorithm == "" {
+			continue
+		}
+
+		digest, err := Parse(algorithm + ":" + value)
+		if err != nil {
+			continue
+		}
+
+		name = strings.TrimPrefix(strings.TrimSpace(name), "./")
+		if existing, ok := file.digests[name]; ok && existing != digest {
+			return nil, fmt.Errorf("checksum file lists %s more than once with different hashes", name)
+		}
+		file.digests[name] = digest
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(file.digests) == 0 {
+		return nil, fmt.Errorf("checksum file has no entries in a known format")
+	}
+
+	return file, nil
+}
+
+// Lookup returns the hash listed for a file name. Entries with a folder, such as dist/tool.tar.gz,
+// match by their base name when no entry has the exact name and only one entry has that base name.
+func (f *ChecksumFile) Lookup(name string) (Digest, error) {
+	if digest, ok := f.digests[name]; ok {
+		return digest, nil
+	}
+
+	var found []Digest
+	for entry, digest := range f.digests {
+		if path.Base(entry) == name {
+			found = append(found, digest)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return Digest{}, fmt.Errorf("checksum file does not list %s", name)
+	case 1:
+		return found[0], nil
+	default:
+		return Digest{}, fmt.Errorf("checksum file lists %s in more than one folder", name)
+	}
+}
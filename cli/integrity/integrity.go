@@ -0,0 +1,191 @@
+package integrity
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"lukechampine.com/blake3"
+)
+
+// Represents the expected hash of a file under a registered algorithm
+type Digest struct {
+	// Name of the algorithm i.e "sha512"
+	Algorithm string
+
+	// Lower case hex encoded hash
+	Hex string
+}
+
+// String returns the digest as algorithm:hex, the form recorded in the state and lock files
+func (d Digest) String() string {
+	return d.Algorithm + ":" + d.Hex
+}
+
+var (
+	mu      sync.RWMutex
+	hashers = map[string]func() hash.Hash{}
+)
+
+func init() {
+	Register("sha256", sha256.New)
+	Register("sha384", sha512.New384)
+	Register("sha512", sha512.New)
+	Register("blake3", func() hash.Hash { return blake3.New(32, nil) })
+}
+
+// Register makes an algorithm usable in integrity strings, replacing any algorithm of the same name
+func Register(name string, newHash func() hash.Hash) {
+	mu.Lock()
+	defer mu.Unlock()
+	hashers[strings.ToLower(name)] = newHash
+}
+
+// Algorithms returns the names of the registered algorithms, sorted
+func Algorithms() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns a new hash of a registered algorithm
+func New(algorithm string) (hash.Hash, error) {
+	mu.RLock()
+	newHash, ok := hashers[algorithm]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %q, expected one of %v", algorithm, Algorithms())
+	}
+	return newHash(), nil
+}
+
+// Parse reads an integrity string, either algorithm:hex such as "sha512:9b71d2…"
+// or a subresource integrity string such as "sha512-m3HSJL…" with the hash in base64
+func Parse(s string) (Digest, error) {
+	s = strings.TrimSpace(s)
+
+	if algorithm, value, ok := strings.Cut(s, ":"); ok {
+		return newDigest(strings.ToLower(algorithm), strings.ToLower(value), hex.DecodeString, s)
+	}
+
+	if algorithm, value, ok := strings.Cut(s, "-"); ok {
+		return newDigest(strings.ToLower(algorithm), value, base64.StdEncoding.DecodeString, s)
+	}
+
+	return Digest{}, fmt.Errorf("invalid integrity %q, expected <algorithm>:<hex> or <algorithm>-<base64>", s)
+}
+
+// newDigest decodes the hash of an integrity string and checks it has the size of the algorithm's hash
+func newDigest(algorithm, value string, decode func(string) ([]byte, error), s string) (Digest, error) {
+	h, err := New(algorithm)
+	if err != nil {
+		return Digest{}, fmt.Errorf("invalid integrity %q: %w", s, err)
+	}
+
+	sum, err := decode(value)
+	if err != nil || len(sum) != h.Size() {
+		return Digest{}, fmt.Errorf("invalid integrity %q, expected a %d byte %s hash", s, h.Size(), algorithm)
+	}
+
+	return Digest{Algorithm: algorithm, Hex: hex.EncodeToString(sum)}, nil
+}
+
+// HashFile returns the hex encoded hash of a file under a registered algorithm
+func HashFile(path, algorithm string) (string, error) {
+	h, err := New(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	sum, err := SumFile(path, h)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+// SumFile returns the raw hash of a file under h, which may also be a hash that is not registered
+// such as the BLAKE2b-512 minisign signs
+func SumFile(path string, h hash.Hash) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, fmt.Errorf("failed to hash file %s: %w", path, err)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// Sums returns the hex encoded hashes of a file under several algorithms, reading it once
+func Sums(path string, algorithms []string) (map[string]string, error) {
+	hashes := map[string]hash.Hash{}
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		h, err := New(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		hashes[algorithm] = h
+		writers = append(writers, h)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return nil, fmt.Errorf("failed to hash file %s: %w", path, err)
+	}
+
+	sums := map[string]string{}
+	for algorithm, h := range hashes {
+		sums[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums, nil
+}
+
+// Verify checks that a file matches a digest
+func Verify(path string, expected Digest) error {
+	actual, err := HashFile(path, expected.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected.Hex {
+		return fmt.Errorf("%s mismatch for %s: expected %s, got %s", strings.ToUpper(expected.Algorithm), path, expected.Hex, actual)
+	}
+
+	return nil
+}
+
+// IsHex reports whether s is a lower case hex encoded hash of a registered algorithm
+func IsHex(algorithm, s string) bool {
+	h, err := New(algorithm)
+	if err != nil || len(s) != h.Size()*2 {
+		return false
+	}
+	_, err = hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
@@ -0,0 +1,256 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+	"github.com/UmbrellaCrow612/binman/cli/state"
+	"gopkg.in/yaml.v2"
+)
+
+// Name of the lock file, kept next to binman.yml
+const FileName = "binman.lock"
+
+// Version of the lock file format
+const formatVersion = 1
+
+// Options selecting every platform and architecture, the lock always covers the whole config
+var allTargets = &args.Options{}
+
+// Represents binman.lock, a record of exactly what an install produced
+type Lock struct {
+	Version int      `yaml:"version"`
+	Targets []Target `yaml:"targets"`
+}
+
+// Represents a locked platform and architecture build of a binary
+type Target struct {
+	Name     string `yaml:"name"`
+	Platform string `yaml:"platform"`
+	Arch     string `yaml:"arch"`
+
+	// URL from binman.yml
+	URL string `yaml:"url"`
+
+	// URL the archive was served from after redirects
+	ResolvedURL string `yaml:"resolved_url"`
+
+	// Hash of the archive as algorithm:hex
+	Integrity string `yaml:"integrity"`
+
+	// Size of the archive in bytes
+	Size int64 `yaml:"size"`
+
+	// Files installed into bin/<name>/<platform>/<arch>
+	Files []File `yaml:"files"`
+}
+
+// Represents an installed file
+type File struct {
+	// Path relative to bin/<name>/<platform>/<arch> using forward slashes
+	Path string `yaml:"path"`
+
+	SHA256 string `yaml:"sha256"`
+
+	// Unix permission bits in octal i.e 0755
+	Mode string `yaml:"mode"`
+}
+
+// Path returns where the lock file of a project lives
+func Path(projectPath string) string {
+	return filepath.Join(projectPath, FileName)
+}
+
+// Load reads the lock file of a project, returning nil when there is none
+func Load(projectPath string) (*Lock, error) {
+	data, err := os.ReadFile(Path(projectPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	var lk Lock
+	if err := yaml.Unmarshal(data, &lk); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", Path(projectPath), err)
+	}
+
+	if lk.Version != formatVersion {
+		return nil, fmt.Errorf("lock file %s has unsupported version %d", Path(projectPath), lk.Version)
+	}
+
+	return &lk, nil
+}
+
+// Save writes the lock file of a project
+func (l *Lock) Save(projectPath string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	header := "# Generated by binman, do not edit by hand\n"
+	return os.WriteFile(Path(projectPath), append([]byte(header), data...), 0644)
+}
+
+// Get returns the locked entry of a target if there is one
+func (l *Lock) Get(target shared.Target) (*Target, bool) {
+	if l == nil {
+		return nil, false
+	}
+
+	for i := range l.Targets {
+		t := &l.Targets[i]
+		if t.Name == target.Binary.NAME && t.Platform == target.Platform && t.Arch == target.Arch {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Build creates the lock for every target defined in the config from what the state says is installed.
+// Targets not installed in this project keep their entry from previous when their URL and hash did not change.
+func Build(config *shared.Config, st *state.State, previous *Lock) *Lock {
+	lk := &Lock{Version: formatVersion, Targets: []Target{}}
+
+	for _, target := range config.Targets(allTargets) {
+		// Empty when the hash is in a checksum file that was not read, the recorded one is then kept
+		hash := target.Integrity()
+
+		if entry, ok := st.Get(target); ok && entry.URL == target.URL() && (hash == "" || entry.Integrity == hash) {
+			lk.Targets = append(lk.Targets, fromState(entry))
+			continue
+		}
+
+		if locked, ok := previous.Get(target); ok && locked.URL == target.URL() && (hash == "" || locked.Integrity == hash) {
+			lk.Targets = append(lk.Targets, *locked)
+		}
+	}
+
+	lk.sort()
+	return lk
+}
+
+// Diff lists how the config differs from the lock for the selected targets, and any
+// locked binary, platform or architecture no longer in the config. Hashes in a checksum file
+// that was not read are not compared, see fetch.ResolveChecksums
+func (l *Lock) Diff(config *shared.Config, targets []shared.Target) []string {
+	var diffs []string
+
+	for _, target := range targets {
+		locked, ok := l.Get(target)
+		switch {
+		case !ok:
+			diffs = append(diffs, target.String()+" is not in the lock file")
+		case locked.URL != target.URL():
+			diffs = append(diffs, fmt.Sprintf("%s url is %s but the lock has %s", target, target.URL(), locked.URL))
+		case target.Integrity() != "" && locked.Integrity != target.Integrity():
+			diffs = append(diffs, fmt.Sprintf("%s hash is %s but the lock has %s", target, target.Integrity(), locked.Integrity))
+		}
+	}
+
+	if l == nil {
+		return diffs
+	}
+
+	defined := map[string]bool{}
+	for _, target := range config.Targets(allTargets) {
+		defined[target.String()] = true
+	}
+	for _, locked := range l.Targets {
+		key := locked.Name + "/" + locked.Platform + "/" + locked.Arch
+		if !defined[key] {
+			diffs = append(diffs, key+" is in the lock file but not in the config")
+		}
+	}
+
+	return diffs
+}
+
+// DiffFiles lists how installed files differ from the ones locked for a target
+func (t *Target) DiffFiles(files []state.File) []string {
+	var diffs []string
+
+	installed := map[string]File{}
+	for _, file := range files {
+		installed[file.Path] = fromStateFile(file)
+	}
+
+	for _, locked := range t.Files {
+		file, ok := installed[locked.Path]
+		switch {
+		case !ok:
+			diffs = append(diffs, "locked file "+locked.Path+" was not installed")
+		case file.SHA256 != locked.SHA256:
+			diffs = append(diffs, fmt.Sprintf("file %s has sha256 %s but the lock has %s", locked.Path, file.SHA256, locked.SHA256))
+		case file.Mode != locked.Mode:
+			diffs = append(diffs, fmt.Sprintf("file %s has mode %s but the lock has %s", locked.Path, file.Mode, locked.Mode))
+		}
+		delete(installed, locked.Path)
+	}
+
+	for _, file := range files {
+		if _, ok := installed[file.Path]; ok {
+			diffs = append(diffs, "file "+file.Path+" is not in the lock file")
+		}
+	}
+
+	return diffs
+}
+
+func (l *Lock) sort() {
+	sort.Slice(l.Targets, func(i, j int) bool {
+		a, b := l.Targets[i], l.Targets[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Platform != b.Platform {
+			return a.Platform < b.Platform
+		}
+		return a.Arch < b.Arch
+	})
+}
+
+func fromState(entry *state.Entry) Target {
+	target := Target{
+		Name:        entry.Name,
+		Platform:    entry.Platform,
+		Arch:        entry.Arch,
+		URL:         entry.URL,
+		ResolvedURL: entry.ResolvedURL,
+		Integrity:   entry.Integrity,
+		Size:        entry.Size,
+		Files:       []File{},
+	}
+
+	for _, file := range entry.Files {
+		target.Files = append(target.Files, fromStateFile(file))
+	}
+
+	return target
+}
+
+func fromStateFile(file state.File) File {
+	return File{
+		Path:   file.Path,
+		SHA256: file.SHA256,
+		Mode:   formatMode(file.Mode),
+	}
+}
+
+// formatMode writes permission bits as a 4 digit octal string
+func formatMode(mode fs.FileMode) string {
+	s := strconv.FormatUint(uint64(mode.Perm()), 8)
+	for len(s) < 4 {
+		s = "0" + s
+	}
+	return s
+}
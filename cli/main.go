@@ -2,50 +2,28 @@ package main
 
 import (
 	"github.com/UmbrellaCrow612/binman/cli/args"
-	"github.com/UmbrellaCrow612/binman/cli/cleaner"
-	"github.com/UmbrellaCrow612/binman/cli/extractor"
-	"github.com/UmbrellaCrow612/binman/cli/fetch"
-	"github.com/UmbrellaCrow612/binman/cli/pattern"
-	"github.com/UmbrellaCrow612/binman/cli/printer"
-	"github.com/UmbrellaCrow612/binman/cli/yml"
+	"github.com/UmbrellaCrow612/binman/cli/commands"
 )
 
 func main() {
 	options := args.Parse()
-	config := yml.Parse(options)
 
-	cleaner.CleanStart(options)
-
-	for _, bin := range config.Binaries {
-		err := fetch.FetchAndStoreBinary(&bin, options)
-		if err != nil {
-			printer.ExitError(err.Error())
-
-		}
+	switch options.Command {
+	case "install":
+		commands.Install(options)
+	case "verify":
+		commands.Verify(options)
+	case "list":
+		commands.List(options)
+	case "clean":
+		commands.Clean(options)
+	case "which":
+		commands.Which(options)
+	case "init":
+		commands.Init(options)
+	case "cache":
+		commands.Cache(options)
+	case "hash":
+		commands.Hash(options)
 	}
-
-	err := extractor.Extract(options)
-	if err != nil {
-		printer.ExitError(err.Error())
-	}
-
-	for _, bin := range config.Binaries {
-		err := extractor.CopyToBin(&bin, options)
-		if err != nil {
-			printer.ExitError(err.Error())
-		}
-	}
-
-	if !options.NoClean {
-		for _, bin := range config.Binaries {
-			err := pattern.CleanWithPattern(&bin, options)
-			if err != nil {
-				printer.ExitError(err.Error())
-			}
-		}
-	} else {
-		printer.PrintSuccess("No clean enabled skipping clean")
-	}
-
-	cleaner.CleanEnd(options)
 }
@@ -0,0 +1,211 @@
+package pattern
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+)
+
+// Architectures of ELF machines, mips is split into mips and mipsel by byte order
+var elfArchs = map[elf.Machine]string{
+	elf.EM_X86_64:    "x64",
+	elf.EM_386:       "ia32",
+	elf.EM_AARCH64:   "arm64",
+	elf.EM_ARM:       "arm",
+	elf.EM_LOONGARCH: "loong64",
+	elf.EM_PPC64:     "ppc64",
+	elf.EM_RISCV:     "riscv64",
+	elf.EM_S390:      "s390x",
+}
+
+// Architectures of PE machines
+var peArchs = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_AMD64:       "x64",
+	pe.IMAGE_FILE_MACHINE_I386:        "ia32",
+	pe.IMAGE_FILE_MACHINE_ARM64:       "arm64",
+	pe.IMAGE_FILE_MACHINE_ARMNT:       "arm",
+	pe.IMAGE_FILE_MACHINE_LOONGARCH64: "loong64",
+	pe.IMAGE_FILE_MACHINE_RISCV64:     "riscv64",
+}
+
+// Architectures of Mach-O cpus
+var machoArchs = map[macho.Cpu]string{
+	macho.CpuAmd64: "x64",
+	macho.Cpu386:   "ia32",
+	macho.CpuArm64: "arm64",
+	macho.CpuArm:   "arm",
+	macho.CpuPpc64: "ppc64",
+}
+
+// CheckArch reads the ELF, PE or Mach-O header of every file in binDir matching the pattern of the target
+// and reports the ones built for another platform or architecture than the target, with a warning or
+// as an error depending on mode, see shared.ArchChecks. Files that are not executables such as scripts are skipped.
+func CheckArch(target shared.Target, binDir, mode string) error {
+	if mode == shared.ArchCheckOff {
+		return nil
+	}
+
+	compliedRegexMap, err := target.Binary.CompilePatternsMap()
+	if err != nil {
+		return err
+	}
+
+	regex, ok := compliedRegexMap[target.Platform][target.Arch]
+	if !ok {
+		return nil
+	}
+
+	var mismatches []string
+	err = filepath.WalkDir(binDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !regex.MatchString(d.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(binDir, path)
+		if err != nil {
+			return err
+		}
+
+		platform, archs, ok, err := executableTarget(path)
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s has an unreadable header: %v", rel, err))
+			return nil
+		}
+		if ok && (platform != target.Platform || !slices.Contains(archs, target.Arch)) {
+			mismatches = append(mismatches, fmt.Sprintf("%s is a %s %s executable", rel, platform, strings.Join(archs, "/")))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	if mode == shared.ArchCheckError {
+		return fmt.Errorf("executables do not match %s/%s:\n  %s", target.Platform, target.Arch, strings.Join(mismatches, "\n  "))
+	}
+
+	for _, mismatch := range mismatches {
+		printer.PrintWarning(target.String() + ": " + mismatch)
+	}
+	return nil
+}
+
+// executableTarget returns the platform and architectures an executable was built for, from its header.
+// Universal Mach-O files hold several architectures, machines binman has no name for are returned by
+// their header name. ok is false when the file is not an ELF, PE or Mach-O file.
+func executableTarget(path string) (platform string, archs []string, ok bool, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", nil, false, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		// Shorter than any header
+		return "", nil, false, nil
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte(elf.ELFMAG)):
+		f, err := elf.NewFile(file)
+		if err != nil {
+			return "", nil, false, err
+		}
+		return elfPlatform(f), []string{elfArch(f)}, true, nil
+
+	case bytes.HasPrefix(magic, []byte("MZ")):
+		f, err := pe.NewFile(file)
+		if err != nil {
+			// A DOS executable without a PE header
+			return "", nil, false, nil
+		}
+		arch, known := peArchs[f.Machine]
+		if !known {
+			arch = fmt.Sprintf("machine 0x%x", f.Machine)
+		}
+		return "windows", []string{arch}, true, nil
+
+	case isMachO(magic):
+		f, err := macho.NewFile(file)
+		if err != nil {
+			return "", nil, false, err
+		}
+		return "darwin", []string{machoArch(f.Cpu)}, true, nil
+
+	case bytes.Equal(magic, []byte{0xca, 0xfe, 0xba, 0xbe}):
+		f, err := macho.NewFatFile(file)
+		if err != nil {
+			// Java class files share the magic of universal binaries
+			return "", nil, false, nil
+		}
+		for _, a := range f.Arches {
+			archs = append(archs, machoArch(a.Cpu))
+		}
+		return "darwin", archs, true, nil
+	}
+
+	return "", nil, false, nil
+}
+
+// elfPlatform returns the platform of an ELF file, linux unless its OS ABI names another system
+func elfPlatform(f *elf.File) string {
+	switch f.OSABI {
+	case elf.ELFOSABI_NONE, elf.ELFOSABI_LINUX:
+		return "linux"
+	default:
+		return strings.ToLower(strings.TrimPrefix(f.OSABI.String(), "ELFOSABI_"))
+	}
+}
+
+// elfArch returns the architecture of an ELF file
+func elfArch(f *elf.File) string {
+	if f.Machine == elf.EM_MIPS {
+		if f.Data == elf.ELFDATA2LSB {
+			return "mipsel"
+		}
+		return "mips"
+	}
+
+	if arch, ok := elfArchs[f.Machine]; ok {
+		return arch
+	}
+	return f.Machine.String()
+}
+
+// machoArch returns the architecture of a Mach-O cpu
+func machoArch(cpu macho.Cpu) string {
+	if arch, ok := machoArchs[cpu]; ok {
+		return arch
+	}
+	return cpu.String()
+}
+
+// isMachO reports whether magic starts a 32 or 64 bit Mach-O file of either byte order
+func isMachO(magic []byte) bool {
+	for _, m := range []uint32{macho.Magic32, macho.Magic64} {
+		be := []byte{byte(m >> 24), byte(m >> 16), byte(m >> 8), byte(m)}
+		le := []byte{byte(m), byte(m >> 8), byte(m >> 16), byte(m >> 24)}
+		if bytes.Equal(magic, be) || bytes.Equal(magic, le) {
+			return true
+		}
+	}
+	return false
+}
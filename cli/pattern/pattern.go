@@ -1,84 +1,111 @@
 package pattern
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
-	"slices"
 
-	"github.com/UmbrellaCrow612/binman/cli/args"
 	"github.com/UmbrellaCrow612/binman/cli/printer"
 	"github.com/UmbrellaCrow612/binman/cli/shared"
 )
 
-// Cleans a specific binary's bin with patterns defined for it
-func CleanWithPattern(bin *shared.Binary, options *args.Options) error {
-	baseBinDir := filepath.Join(options.Path, "bin", bin.NAME)
-
-	compliedRegexMap, err := bin.CompilePatternsMap()
+// Cleans the files of a target in binDir with the pattern defined for it
+func CleanWithPattern(target shared.Target, binDir string) error {
+	compliedRegexMap, err := target.Binary.CompilePatternsMap()
 	if err != nil {
 		return err
 	}
 
-	for platform, archAndUrl := range bin.URLS {
-		if len(options.SpecificPlatformBuilds) > 0 &&
-			!slices.Contains(options.SpecificPlatformBuilds, platform) {
-			continue
-		}
-
-		for arch := range archAndUrl {
-			if len(options.SpecificArchBuilds) > 0 && !slices.Contains(options.SpecificArchBuilds, arch) {
-				continue
-			}
+	regex, ok := compliedRegexMap[target.Platform][target.Arch]
+	if !ok {
+		printer.PrintWarning("Platform " + target.Platform + " " + target.Arch + " has no defined pattern, skipping")
+		return nil
+	}
 
-			regex, ok := compliedRegexMap[platform][arch]
-			if !ok {
-				printer.PrintWarning("Platform " + platform + " " + arch + " has no defined pattern, skipping")
-				continue
-			}
+	info, err := os.Stat(binDir)
+	if os.IsNotExist(err) || !info.IsDir() {
+		return nil
+	} else if err != nil {
+		return err
+	}
 
-			finalBinDir := filepath.Join(baseBinDir, platform, arch)
-			info, err := os.Stat(finalBinDir)
-			if os.IsNotExist(err) || !info.IsDir() {
-				continue
-			} else if err != nil {
-				return err
-			}
+	// Remove files not matching regex
+	err = filepath.WalkDir(binDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !regex.MatchString(d.Name()) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-			// Remove files not matching regex
-			err = filepath.WalkDir(finalBinDir, func(path string, d os.DirEntry, err error) error {
-				if err != nil {
-					return err
-				}
-				if !d.IsDir() && !regex.MatchString(d.Name()) {
-					return os.Remove(path)
-				}
-				return nil
-			})
-			if err != nil {
-				return err
+	// Remove empty directories, keeping binDir itself
+	err = filepath.Walk(binDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != binDir {
+			entries, readErr := os.ReadDir(path)
+			if readErr != nil {
+				return readErr
 			}
-
-			// Remove empty directories
-			err = filepath.Walk(finalBinDir, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if info.IsDir() {
-					entries, readErr := os.ReadDir(path)
-					if readErr != nil {
-						return readErr
-					}
-					if len(entries) == 0 {
-						return os.Remove(path)
-					}
-				}
-				return nil
-			})
-			if err != nil {
-				return err
+			if len(entries) == 0 {
+				return os.Remove(path)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
+
+// MarkExecutable makes every file in binDir matching the pattern of a linux or darwin target executable,
+// as archives made on Windows carry no permissions
+func MarkExecutable(target shared.Target, binDir string) error {
+	if target.Platform == "windows" {
+		return nil
+	}
+
+	compliedRegexMap, err := target.Binary.CompilePatternsMap()
+	if err != nil {
+		return err
+	}
+
+	regex, ok := compliedRegexMap[target.Platform][target.Arch]
+	if !ok {
+		return nil
+	}
+
+	return filepath.WalkDir(binDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !regex.MatchString(d.Name()) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		// Executable for whoever can read it
+		mode := info.Mode().Perm()
+		executable := mode | (mode&0444)>>2
+		if executable == mode {
+			return nil
+		}
+
+		if err := os.Chmod(path, executable); err != nil {
+			return fmt.Errorf("failed to make %s executable: %w", path, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,47 @@
+package printer
+
+// Buffer collects messages so the output of work running concurrently
+// can be printed together as one group once it is done.
+//
+// A Buffer is not safe for concurrent use, each worker should own its own.
+type Buffer struct {
+	messages []bufferedMessage
+}
+
+type bufferedMessage struct {
+	print     func(timestamp, msg string)
+	timestamp string
+	msg       string
+}
+
+// PrintSuccess records a success message to be printed on Flush.
+func (b *Buffer) PrintSuccess(msg string) {
+	b.add(printSuccessAt, msg)
+}
+
+// PrintError records an error message to be printed on Flush.
+func (b *Buffer) PrintError(msg string) {
+	b.add(printErrorAt, msg)
+}
+
+// PrintWarning records a warning message to be printed on Flush.
+func (b *Buffer) PrintWarning(msg string) {
+	b.add(printWarningAt, msg)
+}
+
+// Flush prints every recorded message in the order they were added, keeping
+// the time they were recorded, and empties the buffer.
+func (b *Buffer) Flush() {
+	for _, m := range b.messages {
+		m.print(m.timestamp, m.msg)
+	}
+	b.messages = nil
+}
+
+func (b *Buffer) add(print func(timestamp, msg string), msg string) {
+	b.messages = append(b.messages, bufferedMessage{
+		print:     print,
+		timestamp: getTimestamp(),
+		msg:       msg,
+	})
+}
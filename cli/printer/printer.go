@@ -14,20 +14,17 @@ func getTimestamp() string {
 
 // PrintSuccess prints a success message in green to stdout with timestamp.
 func PrintSuccess(msg string) {
-	green := color.New(color.FgGreen)
-	green.Fprintf(os.Stdout, "[%s] %s\n", getTimestamp(), msg)
+	printSuccessAt(getTimestamp(), msg)
 }
 
 // PrintError prints an error message in red to stderr with timestamp.
 func PrintError(msg string) {
-	red := color.New(color.FgRed)
-	red.Fprintf(os.Stderr, "[%s] ERROR: %s\n", getTimestamp(), msg)
+	printErrorAt(getTimestamp(), msg)
 }
 
 // PrintWarning prints a warning message in yellow to stdout with timestamp.
 func PrintWarning(msg string) {
-	yellow := color.New(color.FgYellow)
-	yellow.Fprintf(os.Stdout, "[%s] WARNING: %s\n", getTimestamp(), msg)
+	printWarningAt(getTimestamp(), msg)
 }
 
 // ExitSuccess prints a success message and exits with code 0.
@@ -41,3 +38,18 @@ func ExitError(msg string) {
 	PrintError(msg)
 	os.Exit(1)
 }
+
+func printSuccessAt(timestamp, msg string) {
+	green := color.New(color.FgGreen)
+	green.Fprintf(os.Stdout, "[%s] %s\n", timestamp, msg)
+}
+
+func printErrorAt(timestamp, msg string) {
+	red := color.New(color.FgRed)
+	red.Fprintf(os.Stderr, "[%s] ERROR: %s\n", timestamp, msg)
+}
+
+func printWarningAt(timestamp, msg string) {
+	yellow := color.New(color.FgYellow)
+	yellow.Fprintf(os.Stdout, "[%s] WARNING: %s\n", timestamp, msg)
+}
@@ -0,0 +1,172 @@
+// Package resolve finds the executables binman installed, the Go equivalent of
+// binmanResolve from the umbr-binman npm package.
+//
+// Executables live in <project>/bin/<name>/<platform>/<arch> where platform and arch
+// use binman's vocabulary, which follows Node's process.platform and process.arch
+// (with win32 called windows) rather than Go's GOOS and GOARCH.
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+	"github.com/UmbrellaCrow612/binman/cli/yml"
+)
+
+// ErrNotFound is returned when no installed executable matches
+var ErrNotFound = errors.New("executable not found")
+
+// Maps GOOS to binman platform names
+var platforms = map[string]string{
+	"linux":   "linux",
+	"darwin":  "darwin",
+	"windows": "windows",
+}
+
+// Maps GOARCH to binman architecture names
+var arches = map[string]string{
+	"amd64":   "x64",
+	"386":     "ia32",
+	"arm":     "arm",
+	"arm64":   "arm64",
+	"loong64": "loong64",
+	"mips":    "mips",
+	"mipsle":  "mipsel",
+	"ppc64":   "ppc64",
+	"ppc64le": "ppc64",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// Platform maps a GOOS value to the binman platform name
+func Platform(goos string) (string, error) {
+	platform, ok := platforms[goos]
+	if !ok {
+		return "", fmt.Errorf("platform %s is not supported by binman", goos)
+	}
+	return platform, nil
+}
+
+// Arch maps a GOARCH value to the binman architecture name
+func Arch(goarch string) (string, error) {
+	arch, ok := arches[goarch]
+	if !ok {
+		return "", fmt.Errorf("architecture %s is not supported by binman", goarch)
+	}
+	return arch, nil
+}
+
+// Host returns the binman platform and architecture of the running program
+func Host() (platform string, arch string, err error) {
+	platform, err = Platform(runtime.GOOS)
+	if err != nil {
+		return "", "", err
+	}
+
+	arch, err = Arch(runtime.GOARCH)
+	if err != nil {
+		return "", "", err
+	}
+
+	return platform, arch, nil
+}
+
+// Executable finds the executable of a binary in binDir/<name>/<platform>/<arch>.
+// Files are searched in lexical order and the first whose name matches pattern is returned.
+// With a nil pattern the folder must hold exactly one file, which is returned.
+func Executable(binDir, name, platform, arch string, pattern *regexp.Regexp) (string, error) {
+	dir := filepath.Join(binDir, name, platform, arch)
+
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%w: %s is not installed for %s/%s", ErrNotFound, name, platform, arch)
+	}
+
+	var matches []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if pattern == nil || pattern.MatchString(d.Name()) {
+			matches = append(matches, path)
+			if pattern != nil {
+				return fs.SkipAll
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	switch {
+	case len(matches) == 0 && pattern != nil:
+		return "", fmt.Errorf("%w: no file in %s matches %s", ErrNotFound, dir, pattern)
+	case len(matches) == 0:
+		return "", fmt.Errorf("%w: %s is empty", ErrNotFound, dir)
+	case len(matches) > 1:
+		return "", fmt.Errorf("%s has no pattern for %s/%s and %s holds %d files", name, platform, arch, dir, len(matches))
+	}
+
+	return matches[0], nil
+}
+
+// Find loads <projectPath>/binman.yml and returns the executable of the named binary
+// for the platform and architecture, found with the pattern configured for them.
+// Empty platform or arch default to the ones of the running program.
+func Find(projectPath, name, platform, arch string) (string, error) {
+	config, err := yml.Load(&args.Options{
+		Path:       projectPath,
+		PathToFile: filepath.Join(projectPath, "binman.yml"),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var bin *shared.Binary
+	for i := range config.Binaries {
+		if config.Binaries[i].NAME == name {
+			bin = &config.Binaries[i]
+			break
+		}
+	}
+	if bin == nil {
+		return "", fmt.Errorf("binary '%s' is not defined in %s", name, filepath.Join(projectPath, "binman.yml"))
+	}
+
+	return FindBinary(projectPath, bin, platform, arch)
+}
+
+// FindBinary returns the executable of an already loaded binary, see Find
+func FindBinary(projectPath string, bin *shared.Binary, platform, arch string) (string, error) {
+	hostPlatform, hostArch, err := Host()
+	if platform == "" {
+		if err != nil {
+			return "", err
+		}
+		platform = hostPlatform
+	}
+	if arch == "" {
+		if err != nil {
+			return "", err
+		}
+		arch = hostArch
+	}
+
+	patterns, err := bin.CompilePatternsMap()
+	if err != nil {
+		return "", err
+	}
+
+	return Executable(filepath.Join(projectPath, "bin"), bin.NAME, platform, arch, patterns[platform][arch])
+}
@@ -0,0 +1,92 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/units"
+)
+
+// Represents a size in bytes, written in binman.yml as a number of bytes or with a unit i.e 512MB
+type ByteSize int64
+
+// UnmarshalYAML reads a size given as a number or as a string with a unit
+func (b *ByteSize) UnmarshalYAML(unmarshal func(any) error) error {
+	var n int64
+	if err := unmarshal(&n); err == nil {
+		if n < 0 {
+			return errors.New("size cannot be negative")
+		}
+		*b = ByteSize(n)
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	n, err := units.ParseBytes(s)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(n)
+	return nil
+}
+
+// Represents the limits protecting against archive bombs and oversized downloads.
+// A zero value means no limit, or in binman.yml that the global limit from the flags applies.
+type Limits struct {
+	// Total bytes an archive may extract to
+	ExtractedSize ByteSize `yaml:"extracted_size"`
+
+	// Number of entries an archive may hold
+	Entries int `yaml:"entries"`
+
+	// How many times larger than the archive its extracted content may be
+	Ratio float64 `yaml:"ratio"`
+
+	// Bytes a download may have
+	DownloadSize ByteSize `yaml:"download_size"`
+}
+
+// validate checks that no limit is negative
+func (l Limits) validate() error {
+	if l.Entries < 0 || l.Ratio < 0 {
+		return errors.New("limits cannot be negative")
+	}
+	return nil
+}
+
+// Limits returns the limits of the target, the ones set on its binary overriding the global flags
+func (t Target) Limits(opts *args.Options) Limits {
+	limits := Limits{
+		ExtractedSize: ByteSize(opts.MaxExtractedSize),
+		Entries:       opts.MaxEntries,
+		Ratio:         opts.MaxRatio,
+		DownloadSize:  ByteSize(opts.MaxDownloadSize),
+	}
+
+	own := t.Binary.LIMITS
+	if own.ExtractedSize > 0 {
+		limits.ExtractedSize = own.ExtractedSize
+	}
+	if own.Entries > 0 {
+		limits.Entries = own.Entries
+	}
+	if own.Ratio > 0 {
+		limits.Ratio = own.Ratio
+	}
+	if own.DownloadSize > 0 {
+		limits.DownloadSize = own.DownloadSize
+	}
+
+	return limits
+}
+
+// String describes the limits for messages
+func (l Limits) String() string {
+	return fmt.Sprintf("extracted_size=%s entries=%d ratio=%g download_size=%s",
+		units.FormatBytes(int64(l.ExtractedSize)), l.Entries, l.Ratio, units.FormatBytes(int64(l.DownloadSize)))
+}
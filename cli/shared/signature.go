@@ -0,0 +1,127 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/signature"
+)
+
+// Represents the detached signature a binary's downloads or its checksum file are verified with.
+// Targets without a url entry use their download URL followed by the suffix.
+type Signature struct {
+	// One of signature.Types
+	Type string `yaml:"type"`
+
+	// Public key the signatures must be made with, in the format of the type.
+	// For openpgp it may hold several armored keys, any of which may have made the signature.
+	PublicKey string `yaml:"public_key"`
+
+	// Files holding public keys, relative to the folder of binman.yml.
+	// OpenPGP key files may be armored or binary, i.e the output of gpg --export.
+	PublicKeyFiles []string `yaml:"public_key_files"`
+
+	// Appended to the download URL to get the signature URL, defaults to .minisig for minisign and .sig otherwise
+	Suffix string `yaml:"suffix"`
+
+	// Signature URL of each platform -> architecture, overriding the suffix
+	URLS map[string]map[string]string `yaml:"urls"`
+
+	// Signature URL of a checksum_signature, overriding the suffix
+	URL string `yaml:"url"`
+
+	// Content of PublicKeyFiles, see Config.LoadKeyFiles
+	keyFiles []string
+}
+
+// validate checks the type and that the public keys can be parsed
+func (s *Signature) validate() error {
+	if _, err := s.Verifier(); err != nil {
+		return fmt.Errorf("signature: %w", err)
+	}
+	return nil
+}
+
+// Keys returns the public key followed by the content of every key file
+func (s *Signature) Keys() []string {
+	var keys []string
+	if strings.TrimSpace(s.PublicKey) != "" {
+		keys = append(keys, s.PublicKey)
+	}
+	return append(keys, s.keyFiles...)
+}
+
+// Verifier parses the public keys into a verifier of the signature type
+func (s *Signature) Verifier() (signature.Verifier, error) {
+	if len(s.PublicKeyFiles) != len(s.keyFiles) {
+		return nil, errors.New("public_key_files were not read")
+	}
+	return signature.NewVerifier(s.Type, s.Keys())
+}
+
+// suffix returns what is appended to a URL to get the URL of its signature
+func (s *Signature) suffix() string {
+	if s.Suffix != "" {
+		return s.Suffix
+	}
+	return signature.DefaultSuffix(s.Type)
+}
+
+// LoadKeyFiles reads the public_key_files of every signature, relative paths being relative to dir
+func (c *Config) LoadKeyFiles(dir string) error {
+	for i := range c.Binaries {
+		bin := &c.Binaries[i]
+
+		for _, sig := range []*Signature{bin.SIGNATURE, bin.CHECKSUM_SIGNATURE} {
+			if sig == nil {
+				continue
+			}
+
+			sig.keyFiles = nil
+			for _, file := range sig.PublicKeyFiles {
+				if !filepath.IsAbs(file) {
+					file = filepath.Join(dir, file)
+				}
+
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("binary '%s': failed to read public key: %w", bin.NAME, err)
+				}
+				sig.keyFiles = append(sig.keyFiles, string(data))
+			}
+		}
+	}
+
+	return nil
+}
+
+// SignatureURL returns where the signature of the target is downloaded from,
+// empty when its binary is not signed
+func (t Target) SignatureURL() string {
+	sig := t.Binary.SIGNATURE
+	if sig == nil {
+		return ""
+	}
+
+	if url, ok := sig.URLS[t.Platform][t.Arch]; ok {
+		return url
+	}
+	return t.URL() + sig.suffix()
+}
+
+// ChecksumSignatureURL returns where the signature of the checksum file is downloaded from,
+// empty when it is not signed
+func (b *Binary) ChecksumSignatureURL() string {
+	sig := b.CHECKSUM_SIGNATURE
+	if sig == nil {
+		return ""
+	}
+
+	if sig.URL != "" {
+		return sig.URL
+	}
+	return b.CHECKSUM_URL + sig.suffix()
+}
@@ -0,0 +1,168 @@
+package shared
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+)
+
+// Represents a single platform and architecture build of a binary
+type Target struct {
+	Binary   *Binary
+	Platform string
+	Arch     string
+}
+
+// String returns the target as name/platform/arch for use in messages
+func (t Target) String() string {
+	return t.Binary.NAME + "/" + t.Platform + "/" + t.Arch
+}
+
+// URL returns the download URL defined for the target
+func (t Target) URL() string {
+	return t.Binary.URLS[t.Platform][t.Arch]
+}
+
+// Digest returns the hash the download of the target must match, from its integrity entry
+// when there is one, then from its sha256 entry and then from the binary's checksum file
+func (t Target) Digest() (integrity.Digest, error) {
+	if value, ok := t.Binary.INTEGRITY[t.Platform][t.Arch]; ok {
+		return integrity.Parse(value)
+	}
+
+	if t.FromChecksumFile() {
+		digest, ok := t.Binary.checksums[t.Platform][t.Arch]
+		if !ok {
+			return integrity.Digest{}, fmt.Errorf("hash of %s was not read from %s", t, t.Binary.CHECKSUM_URL)
+		}
+		return digest, nil
+	}
+
+	sha := strings.ToLower(strings.TrimSpace(t.Binary.SHA256[t.Platform][t.Arch]))
+	if !integrity.IsHex("sha256", sha) {
+		return integrity.Digest{}, fmt.Errorf("invalid sha256 %q for %s, run binman hash to fill it in", t.Binary.SHA256[t.Platform][t.Arch], t)
+	}
+	return integrity.Digest{Algorithm: "sha256", Hex: sha}, nil
+}
+
+// FromChecksumFile reports whether the hash of the target is read from the checksum file of its binary,
+// as it has neither a sha256 nor an integrity entry
+func (t Target) FromChecksumFile() bool {
+	return !t.Binary.HasHash(t.Platform, t.Arch) && t.Binary.CHECKSUM_URL != ""
+}
+
+// Integrity returns the digest of the target as algorithm:hex, the form recorded in the state
+// and lock files. A value that is not a valid digest, such as a placeholder, is returned as written.
+// It is empty for a target whose hash was not read from its checksum file yet.
+func (t Target) Integrity() string {
+	if digest, err := t.Digest(); err == nil {
+		return digest.String()
+	}
+	if t.FromChecksumFile() {
+		return ""
+	}
+	if value, ok := t.Binary.INTEGRITY[t.Platform][t.Arch]; ok {
+		return value
+	}
+	return "sha256:" + t.Binary.SHA256[t.Platform][t.Arch]
+}
+
+// Fingerprint identifies everything in the config and options that changes what the target installs.
+// A target whose fingerprint differs from the one it was installed with gets installed again,
+// so any new setting affecting the install must be added here.
+func (t Target) Fingerprint(opts *args.Options) string {
+	// The checksum file is only read for targets that get installed, so it is named instead of the hash it lists
+	hash := "integrity=" + t.Integrity()
+	if t.FromChecksumFile() {
+		hash = "checksum_url=" + t.Binary.CHECKSUM_URL + " " + t.Binary.CHECKSUM_INTEGRITY
+	}
+
+	parts := []string{
+		"url=" + t.URL(),
+		hash,
+		"pattern=" + t.Binary.PATTERNS[t.Platform][t.Arch],
+		"format=" + t.Binary.FORMAT,
+		"rename=" + t.Binary.RENAME[t.Platform][t.Arch],
+		"layout=" + t.Binary.LAYOUT,
+		"strip_components=" + strconv.Itoa(t.Binary.STRIP_COMPONENTS),
+		"subdir=" + t.Binary.SUBDIR,
+		"nested_depth=" + strconv.Itoa(t.Binary.NESTED_DEPTH),
+		"limits=" + t.limitsFingerprint(opts),
+		"arch_check=" + t.ArchCheck(opts),
+		"signature=" + t.signatureFingerprint(),
+		"clean=" + strconv.FormatBool(!opts.NoClean),
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ArchCheck returns what happens when an executable of the target is built for another platform or
+// architecture, the arch_check of its binary overriding the --arch-check flag
+func (t Target) ArchCheck(opts *args.Options) string {
+	if t.Binary.ARCH_CHECK != "" {
+		return t.Binary.ARCH_CHECK
+	}
+	return opts.ArchCheck
+}
+
+// limitsFingerprint returns the effective limits of the target with exact byte counts,
+// so a stricter limit checks the archive again
+func (t Target) limitsFingerprint(opts *args.Options) string {
+	l := t.Limits(opts)
+	return fmt.Sprintf("%d %d %g %d", l.ExtractedSize, l.Entries, l.Ratio, l.DownloadSize)
+}
+
+// signatureFingerprint returns the signature settings of the target, empty when it is not signed
+func (t Target) signatureFingerprint() string {
+	if t.Binary.SIGNATURE == nil {
+		return ""
+	}
+	sig := t.Binary.SIGNATURE
+	return sig.Type + " " + strings.TrimSpace(strings.Join(sig.Keys(), "\n")) + " " + t.SignatureURL()
+}
+
+// Targets returns every platform and architecture of the binary selected by the options,
+// sorted by platform then architecture so callers always see them in the same order.
+func (b *Binary) Targets(opts *args.Options) []Target {
+	var targets []Target
+
+	for platform, archAndUrl := range b.URLS {
+		if len(opts.SpecificPlatformBuilds) > 0 && !slices.Contains(opts.SpecificPlatformBuilds, platform) {
+			continue
+		}
+
+		for arch := range archAndUrl {
+			if len(opts.SpecificArchBuilds) > 0 && !slices.Contains(opts.SpecificArchBuilds, arch) {
+				continue
+			}
+
+			targets = append(targets, Target{Binary: b, Platform: platform, Arch: arch})
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Platform != targets[j].Platform {
+			return targets[i].Platform < targets[j].Platform
+		}
+		return targets[i].Arch < targets[j].Arch
+	})
+
+	return targets
+}
+
+// Targets returns the selected targets of every binary in config order
+func (c *Config) Targets(opts *args.Options) []Target {
+	var targets []Target
+	for i := range c.Binaries {
+		targets = append(targets, c.Binaries[i].Targets(opts)...)
+	}
+	return targets
+}
@@ -3,9 +3,13 @@ package shared
 import (
 	"errors"
 	"fmt"
+	"path"
 	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
 )
 
 // Represents the binman.yml
@@ -64,6 +68,45 @@ type Binary struct {
 	//
 	SHA256 map[string]map[string]string `yaml:"sha256"`
 
+	// INTEGRITY is the hash of the download for each platform -> architecture under any registered
+	// algorithm, i.e "sha512:<hex>", "blake3:<hex>" or a subresource integrity string "sha512-<base64>".
+	// It takes the place of the sha256 entry of the same platform and architecture.
+	INTEGRITY map[string]map[string]string `yaml:"integrity"`
+
+	// CHECKSUM_URL is a checksum file published with the release, i.e checksums.txt or SHA256SUMS.
+	// Targets without a sha256 or integrity entry are verified against the hash it lists for their file name.
+	CHECKSUM_URL string `yaml:"checksum_url"`
+
+	// CHECKSUM_INTEGRITY optionally pins the hash of the checksum file itself, in the same form as an integrity entry
+	CHECKSUM_INTEGRITY string `yaml:"checksum_integrity"`
+
+	// CHECKSUM_SIGNATURE optionally verifies a detached signature of the checksum file before any hash is read from it,
+	// i.e the SHA256SUMS.sig OpenPGP signature of a HashiCorp release
+	//
+	// Example structure (YAML):
+	//
+	// checksum_signature:
+	//   type: openpgp
+	//   public_key_files: [keys/hashicorp.asc]
+	//
+	CHECKSUM_SIGNATURE *Signature `yaml:"checksum_signature"`
+
+	// SIGNATURE optionally verifies a detached signature of every download before it is extracted
+	//
+	// Example structure (YAML):
+	//
+	// signature:
+	//   type: minisign
+	//   public_key: RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3
+	//   urls:
+	//     linux:
+	//       x86_64: https://example.com/rg-x86_64.tar.gz.minisig
+	//
+	SIGNATURE *Signature `yaml:"signature"`
+
+	// Hashes read from the checksum file for each platform -> architecture, see SetChecksum
+	checksums map[string]map[string]integrity.Digest
+
 	// PATTERNS represents the executable name pattern (regex) for each platform -> architecture.
 	//
 	// Example structure (YAML):
@@ -84,11 +127,84 @@ type Binary struct {
 	//
 	PATTERNS map[string]map[string]string `yaml:"patterns"`
 
+	// FORMAT forces the archive format instead of detecting it from the downloaded file,
+	// one of ArchiveFormats i.e "tar.gz". Empty detects it.
+	FORMAT string `yaml:"format"`
+
+	// RENAME is the file name a download that is not an archive is installed as, for each platform -> architecture.
+	//
+	// Example structure (YAML):
+	//
+	// rename:
+	//   linux:
+	//     x64: jq
+	//   windows:
+	//     x64: jq.exe
+	//
+	RENAME map[string]map[string]string `yaml:"rename"`
+
+	// LAYOUT decides how the files of an archive are placed in bin/<name>/<platform>/<arch>,
+	// LayoutFlatten (the default) or LayoutPreserve
+	LAYOUT string `yaml:"layout"`
+
+	// STRIP_COMPONENTS is how many leading folders are dropped from every path in the archive, like tar --strip-components
+	STRIP_COMPONENTS int `yaml:"strip_components"`
+
+	// SUBDIR is a glob of the folder inside the archive to install instead of everything i.e "ripgrep-*/",
+	// matched after STRIP_COMPONENTS is applied
+	SUBDIR string `yaml:"subdir"`
+
+	// NESTED_DEPTH is how many levels of archives inside the download are extracted as well,
+	// i.e 1 for a .tar.gz inside a .zip. 0 (the default) only extracts the download.
+	NESTED_DEPTH int `yaml:"nested_depth"`
+
+	// ARCH_CHECK decides what happens when a file matching the pattern is an executable built for another
+	// platform or architecture than the one it is installed under, one of ArchChecks. Empty uses --arch-check.
+	ARCH_CHECK string `yaml:"arch_check"`
+
+	// LIMITS overrides the global extraction and download limits for this binary
+	//
+	// Example structure (YAML):
+	//
+	// limits:
+	//   extracted_size: 2GiB
+	//   entries: 50000
+	//   ratio: 200
+	//   download_size: 500MB
+	//
+	LIMITS Limits `yaml:"limits"`
+
 	// EXTRA captures any other key-value pairs in the YAML that are not explicitly mapped.
 	// For example, version: "1.2.3" would go here.
 	EXTRA map[string]any `yaml:",inline"`
 }
 
+const (
+	// Every file is moved into bin/<name>/<platform>/<arch> and cleaned with the pattern
+	LayoutFlatten = "flatten"
+
+	// Files keep the folders they have in the archive and nothing is removed by the pattern
+	LayoutPreserve = "preserve"
+)
+
+const (
+	// A mismatching executable is installed with a warning
+	ArchCheckWarn = "warn"
+
+	// A mismatching executable fails the install of the target
+	ArchCheckError = "error"
+
+	// Executables are not checked
+	ArchCheckOff = "off"
+)
+
+// Values of the arch_check field of a binary
+var ArchChecks = []string{ArchCheckWarn, ArchCheckError, ArchCheckOff}
+
+// Formats that can be set in the format field of a binary. The compressed ones also take a
+// single compressed file instead of a tar, raw installs the download as it is.
+var ArchiveFormats = []string{"zip", "tar", "tar.gz", "tar.xz", "tar.bz2", "tar.zst", "deb", "rpm", "apk", "raw"}
+
 // Validate checks that the binary has required fields
 func (b *Binary) Validate() error {
 	if b.NAME == "" {
@@ -158,37 +274,115 @@ func (b *Binary) Validate() error {
 		return fmt.Errorf("binary '%s' must define urls", b.NAME)
 	}
 
-	if len(b.SHA256) == 0 {
-		return fmt.Errorf("binary '%s' must define sha256", b.NAME)
+	if len(b.SHA256) == 0 && len(b.INTEGRITY) == 0 && b.CHECKSUM_URL == "" {
+		return fmt.Errorf("binary '%s' must define sha256, integrity or checksum_url", b.NAME)
 	}
 
 	for platform, archURLs := range b.URLS {
-		shaArchMap, ok := b.SHA256[platform]
-		if !ok {
-			return fmt.Errorf("binary '%s' missing sha256 definitions for platform '%s'", b.NAME, platform)
-		}
-
 		for arch := range archURLs {
-			if _, ok := shaArchMap[arch]; !ok {
+			if !b.hasChecksum(platform, arch) {
 				return fmt.Errorf(
-					"binary '%s' missing sha256 for platform '%s', architecture '%s'",
+					"binary '%s' missing sha256 or integrity for platform '%s', architecture '%s' and has no checksum_url",
 					b.NAME, platform, arch,
 				)
 			}
 		}
 	}
 
-	for platform, shaArchMap := range b.SHA256 {
-		if _, ok := b.URLS[platform]; !ok {
-			return fmt.Errorf("binary '%s' defines sha256 for platform '%s' but missing in urls", b.NAME, platform)
+	for _, field := range []struct {
+		name    string
+		entries map[string]map[string]string
+	}{{"sha256", b.SHA256}, {"integrity", b.INTEGRITY}} {
+		for platform, archMap := range field.entries {
+			if _, ok := b.URLS[platform]; !ok {
+				return fmt.Errorf("binary '%s' defines %s for platform '%s' but missing in urls", b.NAME, field.name, platform)
+			}
+
+			for arch := range archMap {
+				if _, ok := b.URLS[platform][arch]; !ok {
+					return fmt.Errorf(
+						"binary '%s' defines %s for platform '%s', architecture '%s' but missing in urls",
+						b.NAME, field.name, platform, arch,
+					)
+				}
+			}
+		}
+	}
+
+	for platform, archMap := range b.INTEGRITY {
+		for arch, value := range archMap {
+			if _, err := integrity.Parse(value); err != nil {
+				return fmt.Errorf("binary '%s' platform '%s', architecture '%s': %w", b.NAME, platform, arch, err)
+			}
+		}
+	}
+
+	if b.CHECKSUM_INTEGRITY != "" {
+		if b.CHECKSUM_URL == "" {
+			return fmt.Errorf("binary '%s' defines checksum_integrity without checksum_url", b.NAME)
+		}
+		if _, err := integrity.Parse(b.CHECKSUM_INTEGRITY); err != nil {
+			return fmt.Errorf("binary '%s' checksum_integrity: %w", b.NAME, err)
+		}
+	}
+
+	if b.SIGNATURE != nil {
+		if err := b.SIGNATURE.validate(); err != nil {
+			return fmt.Errorf("binary '%s' %w", b.NAME, err)
 		}
+		if b.SIGNATURE.URL != "" {
+			return fmt.Errorf("binary '%s' signature url is only used by checksum_signature, set urls instead", b.NAME)
+		}
+	}
+
+	if b.CHECKSUM_SIGNATURE != nil {
+		if b.CHECKSUM_URL == "" {
+			return fmt.Errorf("binary '%s' defines checksum_signature without checksum_url", b.NAME)
+		}
+		if err := b.CHECKSUM_SIGNATURE.validate(); err != nil {
+			return fmt.Errorf("binary '%s' checksum_%w", b.NAME, err)
+		}
+		if len(b.CHECKSUM_SIGNATURE.URLS) > 0 {
+			return fmt.Errorf("binary '%s' checksum_signature has a single url, set url instead of urls", b.NAME)
+		}
+	}
+
+	if b.FORMAT != "" && !slices.Contains(ArchiveFormats, b.FORMAT) {
+		return fmt.Errorf("binary '%s' defines invalid format '%s'. valid formats: %v", b.NAME, b.FORMAT, ArchiveFormats)
+	}
 
-		for arch := range shaArchMap {
+	if b.LAYOUT != "" && b.LAYOUT != LayoutFlatten && b.LAYOUT != LayoutPreserve {
+		return fmt.Errorf("binary '%s' defines invalid layout '%s'. valid layouts: [%s %s]", b.NAME, b.LAYOUT, LayoutFlatten, LayoutPreserve)
+	}
+
+	if b.STRIP_COMPONENTS < 0 {
+		return fmt.Errorf("binary '%s' strip_components cannot be negative", b.NAME)
+	}
+
+	if b.NESTED_DEPTH < 0 {
+		return fmt.Errorf("binary '%s' nested_depth cannot be negative", b.NAME)
+	}
+
+	if b.ARCH_CHECK != "" && !slices.Contains(ArchChecks, b.ARCH_CHECK) {
+		return fmt.Errorf("binary '%s' defines invalid arch_check '%s'. valid values: %v", b.NAME, b.ARCH_CHECK, ArchChecks)
+	}
+
+	if _, err := path.Match(b.SUBDIR, ""); err != nil {
+		return fmt.Errorf("binary '%s' defines invalid subdir '%s': %w", b.NAME, b.SUBDIR, err)
+	}
+
+	if err := b.LIMITS.validate(); err != nil {
+		return fmt.Errorf("binary '%s' defines invalid limits: %w", b.NAME, err)
+	}
+
+	for platform, arches := range b.RENAME {
+		for arch, name := range arches {
 			if _, ok := b.URLS[platform][arch]; !ok {
-				return fmt.Errorf(
-					"binary '%s' defines sha256 for platform '%s', architecture '%s' but missing in urls",
-					b.NAME, platform, arch,
-				)
+				return fmt.Errorf("binary '%s' defines rename for platform '%s', architecture '%s' but missing in urls", b.NAME, platform, arch)
+			}
+
+			if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+				return fmt.Errorf("binary '%s' rename for platform '%s', architecture '%s' must be a file name, got '%s'", b.NAME, platform, arch, name)
 			}
 		}
 	}
@@ -239,10 +433,10 @@ func (c *Config) ValidateWithOptions(opts *args.Options) error {
 					)
 				}
 
-				// Check platform exists in SHA256
-				if _, ok := bin.SHA256[platform]; !ok {
+				// Check platform exists in SHA256 or INTEGRITY
+				if _, ok := bin.SHA256[platform]; !ok && bin.INTEGRITY[platform] == nil && bin.CHECKSUM_URL == "" {
 					return fmt.Errorf(
-						"binary '%s' does not define platform '%s' in sha256",
+						"binary '%s' does not define platform '%s' in sha256 or integrity",
 						bin.NAME, platform,
 					)
 				}
@@ -278,10 +472,10 @@ func (c *Config) ValidateWithOptions(opts *args.Options) error {
 						)
 					}
 
-					// SHA256
-					if _, ok := bin.SHA256[platform][arch]; !ok {
+					// SHA256 or INTEGRITY
+					if !bin.hasChecksum(platform, arch) {
 						return fmt.Errorf(
-							"binary '%s' missing SHA256 for platform '%s', architecture '%s'",
+							"binary '%s' missing SHA256 or integrity for platform '%s', architecture '%s'",
 							bin.NAME, platform, arch,
 						)
 					}
@@ -295,6 +489,32 @@ func (c *Config) ValidateWithOptions(opts *args.Options) error {
 	return nil
 }
 
+// hasChecksum reports whether the binary defines a sha256 or integrity entry for a platform and architecture,
+// or a checksum file to read it from
+func (b *Binary) hasChecksum(platform, arch string) bool {
+	return b.HasHash(platform, arch) || b.CHECKSUM_URL != ""
+}
+
+// HasHash reports whether the binary defines a sha256 or integrity entry for a platform and architecture
+func (b *Binary) HasHash(platform, arch string) bool {
+	if _, ok := b.SHA256[platform][arch]; ok {
+		return true
+	}
+	_, ok := b.INTEGRITY[platform][arch]
+	return ok
+}
+
+// SetChecksum records the hash the checksum file lists for a platform and architecture
+func (b *Binary) SetChecksum(platform, arch string, digest integrity.Digest) {
+	if b.checksums == nil {
+		b.checksums = map[string]map[string]integrity.Digest{}
+	}
+	if b.checksums[platform] == nil {
+		b.checksums[platform] = map[string]integrity.Digest{}
+	}
+	b.checksums[platform][arch] = digest
+}
+
 // CompilePatternsMap compiles all patterns and returns a nested map of platform -> architecture -> *regexp.Regexp.
 // If any pattern fails, it returns an error indicating which platform/architecture failed.
 func (b *Binary) CompilePatternsMap() (map[string]map[string]*regexp.Regexp, error) {
@@ -0,0 +1,78 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+)
+
+// cosignKey verifies signatures made by cosign sign-blob with a key pair
+type cosignKey struct {
+	key any
+}
+
+// parseCosignKey reads a PEM public key as written by cosign generate-key-pair, ECDSA P-256 or ed25519
+func parseCosignKey(text string) (*cosignKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(text)))
+	if block == nil {
+		return nil, errors.New("invalid cosign public key, expected a PEM public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("invalid cosign public key: " + err.Error())
+	}
+
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("unsupported cosign public key, ECDSA keys must use P-256")
+		}
+	case ed25519.PublicKey:
+	default:
+		return nil, errors.New("unsupported cosign public key, expected ECDSA P-256 or ed25519")
+	}
+
+	return &cosignKey{key: key}, nil
+}
+
+// Verify checks a base64 signature as written by cosign sign-blob --output-signature
+func (k *cosignKey) Verify(path string, sig []byte) error {
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return errors.New("invalid cosign signature, expected base64")
+	}
+
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		h, err := integrity.New("sha256")
+		if err != nil {
+			return err
+		}
+		digest, err := integrity.SumFile(path, h)
+		if err != nil {
+			return err
+		}
+		if !ecdsa.VerifyASN1(key, digest, signature) {
+			return errors.New("signature does not match")
+		}
+	case ed25519.PublicKey:
+		message, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !ed25519.Verify(key, message, signature) {
+			return errors.New("signature does not match")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Algorithm ids of minisign signatures, legacy ones sign the file and prehashed ones its BLAKE2b-512
+var (
+	minisignLegacy    = []byte("Ed")
+	minisignPrehashed = []byte("ED")
+)
+
+// minisignKey verifies minisign signatures, https://jedisct1.github.io/minisign/
+type minisignKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// parseMinisignKey reads a minisign public key, "RW..." or the content of a .pub file
+func parseMinisignKey(text string) (*minisignKey, error) {
+	data, err := decodeBoxed(text)
+	if err != nil || len(data) != 2+8+ed25519.PublicKeySize || !bytes.Equal(data[:2], minisignLegacy) {
+		return nil, errors.New("invalid minisign public key")
+	}
+	return &minisignKey{id: data[2:10], key: ed25519.PublicKey(data[10:])}, nil
+}
+
+// Verify checks a .minisig file. Besides the signature of the file it checks the global signature,
+// which covers the signature and its trusted comment.
+func (k *minisignKey) Verify(path string, sig []byte) error {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(string(sig)), "\r\n", "\n"), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("invalid minisign signature file")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(data) != 2+8+ed25519.SignatureSize {
+		return errors.New("invalid minisign signature")
+	}
+	algorithm, id, signature := data[:2], data[2:10], data[10:]
+
+	if !bytes.Equal(id, k.id) {
+		return fmt.Errorf("signed with key %s but the public key is %s", keyID(id), keyID(k.id))
+	}
+
+	var message []byte
+	switch {
+	case bytes.Equal(algorithm, minisignPrehashed):
+		h, _ := blake2b.New512(nil)
+		message, err = integrity.SumFile(path, h)
+	case bytes.Equal(algorithm, minisignLegacy):
+		message, err = os.ReadFile(path)
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm %q", algorithm)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(k.key, message, signature) {
+		return errors.New("signature does not match")
+	}
+
+	globalSignature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return errors.New("invalid minisign global signature")
+	}
+
+	trustedComment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(k.key, append(signature, trustedComment...), globalSignature) {
+		return errors.New("trusted comment signature does not match")
+	}
+
+	return nil
+}
+
+// keyID formats a minisign or signify key id the way the tools print it
+func keyID(id []byte) string {
+	reversed := make([]byte, len(id))
+	for i := range id {
+		reversed[i] = id[len(id)-1-i]
+	}
+	return strings.ToUpper(hex.EncodeToString(reversed))
+}
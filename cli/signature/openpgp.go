@@ -0,0 +1,75 @@
+package signature
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// Start of an ASCII armored key and signature
+const (
+	armoredKeyHeader       = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	armoredSignatureHeader = "-----BEGIN PGP SIGNATURE-----"
+)
+
+// openpgpKeys verifies detached OpenPGP signatures such as the .sig and .asc files
+// published next to SHA256SUMS by HashiCorp or Node
+type openpgpKeys struct {
+	keyring openpgp.EntityList
+}
+
+// parseOpenPGPKeys reads every key of the given armored key blocks or binary key files into one key ring
+func parseOpenPGPKeys(keys []string) (*openpgpKeys, error) {
+	var keyring openpgp.EntityList
+
+	for _, key := range keys {
+		if !strings.Contains(key, armoredKeyHeader) {
+			entities, err := openpgp.ReadKeyRing(strings.NewReader(key))
+			if err != nil {
+				return nil, fmt.Errorf("invalid OpenPGP public key: %w", err)
+			}
+			keyring = append(keyring, entities...)
+			continue
+		}
+
+		// ReadArmoredKeyRing stops after the first block, so several pasted keys are read one by one
+		blocks := strings.Split(key, armoredKeyHeader)[1:]
+		for _, block := range blocks {
+			entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKeyHeader + block))
+			if err != nil {
+				return nil, fmt.Errorf("invalid OpenPGP public key: %w", err)
+			}
+			keyring = append(keyring, entities...)
+		}
+	}
+
+	if len(keyring) == 0 {
+		return nil, errors.New("no OpenPGP public key found")
+	}
+
+	return &openpgpKeys{keyring: keyring}, nil
+}
+
+// Verify checks a binary or ASCII armored detached signature made by any key of the key ring
+func (k *openpgpKeys) Verify(path string, sig []byte) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if bytes.Contains(sig, []byte(armoredSignatureHeader)) {
+		_, err = openpgp.CheckArmoredDetachedSignature(k.keyring, file, bytes.NewReader(sig), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(k.keyring, file, bytes.NewReader(sig), nil)
+	}
+
+	if err != nil {
+		return fmt.Errorf("signature does not match: %w", err)
+	}
+	return nil
+}
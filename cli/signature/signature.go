@@ -0,0 +1,75 @@
+package signature
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Supported signature types, the type field of a binary's signature config
+const (
+	Minisign = "minisign"
+	Signify  = "signify"
+	Cosign   = "cosign"
+	OpenPGP  = "openpgp"
+)
+
+// Types lists the supported signature types
+var Types = []string{Minisign, Signify, Cosign, OpenPGP}
+
+// Verifier checks a detached signature of a file against a public key
+type Verifier interface {
+	// Verify returns an error unless sig is a valid signature of the file at path
+	Verify(path string, sig []byte) error
+}
+
+// NewVerifier parses the public keys of a signature type into a verifier for them.
+// OpenPGP signatures may be made by any of the keys, the other types take exactly one.
+func NewVerifier(kind string, keys []string) (Verifier, error) {
+	if !slices.Contains(Types, kind) {
+		return nil, fmt.Errorf("unknown signature type %q, expected one of %v", kind, Types)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no public key")
+	}
+	if kind == OpenPGP {
+		return parseOpenPGPKeys(keys)
+	}
+	if len(keys) > 1 {
+		return nil, fmt.Errorf("%s takes a single public key, got %d", kind, len(keys))
+	}
+
+	switch kind {
+	case Minisign:
+		return parseMinisignKey(keys[0])
+	case Signify:
+		return parseSignifyKey(keys[0])
+	case Cosign:
+		return parseCosignKey(keys[0])
+	default:
+		return nil, fmt.Errorf("unknown signature type %q, expected one of %v", kind, Types)
+	}
+}
+
+// DefaultSuffix returns what is appended to a download URL to get its signature URL
+func DefaultSuffix(kind string) string {
+	if kind == Minisign {
+		return ".minisig"
+	}
+	return ".sig"
+}
+
+// decodeBoxed decodes the base64 line of a minisign or signify key or signature. The text is either
+// that line alone or a whole file, where it follows an "untrusted comment:" line.
+func decodeBoxed(text string) ([]byte, error) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+		return base64.StdEncoding.DecodeString(line)
+	}
+	return nil, fmt.Errorf("no key or signature found")
+}
@@ -0,0 +1,49 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Algorithm id of signify keys and signatures
+var signifyAlgorithm = []byte("Ed")
+
+// signifyKey verifies detached OpenBSD signify signatures
+type signifyKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// parseSignifyKey reads a signify public key, "RWS..." or the content of a .pub file
+func parseSignifyKey(text string) (*signifyKey, error) {
+	data, err := decodeBoxed(text)
+	if err != nil || len(data) != 2+8+ed25519.PublicKeySize || !bytes.Equal(data[:2], signifyAlgorithm) {
+		return nil, errors.New("invalid signify public key")
+	}
+	return &signifyKey{id: data[2:10], key: ed25519.PublicKey(data[10:])}, nil
+}
+
+// Verify checks a detached .sig file made by signify -S
+func (k *signifyKey) Verify(path string, sig []byte) error {
+	data, err := decodeBoxed(string(sig))
+	if err != nil || len(data) != 2+8+ed25519.SignatureSize || !bytes.Equal(data[:2], signifyAlgorithm) {
+		return errors.New("invalid signify signature")
+	}
+
+	if id := data[2:10]; !bytes.Equal(id, k.id) {
+		return fmt.Errorf("signed with key %s but the public key is %s", keyID(id), keyID(k.id))
+	}
+
+	message, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(k.key, message, data[10:]) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
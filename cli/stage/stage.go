@@ -0,0 +1,102 @@
+package stage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/UmbrellaCrow612/binman/cli/args"
+	"github.com/UmbrellaCrow612/binman/cli/printer"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+)
+
+// Name of the folder inside bin that holds staged installs
+const dirName = ".staging"
+
+// Represents the install of a target being built in a temporary folder.
+// The live bin/<name>/<platform>/<arch> folder is only replaced on Commit,
+// so a failure part way through leaves the previous install untouched.
+type Stage struct {
+	// Folder to build the install in
+	Dir string
+
+	// Folder the install replaces on commit
+	finalDir string
+
+	// Folder holding staged and replaced installs
+	stagingDir string
+}
+
+// New creates an empty staging folder for a target next to the bin folder
+// so it can be renamed into place
+func New(target shared.Target, options *args.Options) (*Stage, error) {
+	stagingDir := Path(options)
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(stagingDir, target.Binary.NAME+"-"+target.Platform+"-"+target.Arch+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	if err := os.Chmod(dir, 0755); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	return &Stage{
+		Dir:        dir,
+		finalDir:   filepath.Join(options.Path, "bin", target.Binary.NAME, target.Platform, target.Arch),
+		stagingDir: stagingDir,
+	}, nil
+}
+
+// Path returns the folder staged installs are built in
+func Path(options *args.Options) string {
+	return filepath.Join(options.Path, "bin", dirName)
+}
+
+// Commit swaps the staged folder into place. The previous install is moved aside
+// first and restored if the staged folder cannot be moved in. Once the swap happened the new
+// install is live, so a previous install that cannot be removed is only warned about and
+// left in the staging folder for the next run to clean up.
+func (s *Stage) Commit() error {
+	if err := os.MkdirAll(filepath.Dir(s.finalDir), 0755); err != nil {
+		return fmt.Errorf("failed to create bin directory: %w", err)
+	}
+
+	previous := ""
+	if _, err := os.Lstat(s.finalDir); err == nil {
+		previous = s.Dir + ".previous"
+		if err := os.Rename(s.finalDir, previous); err != nil {
+			return fmt.Errorf("failed to move aside %s: %w", s.finalDir, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Rename(s.Dir, s.finalDir); err != nil {
+		if previous != "" {
+			os.Rename(previous, s.finalDir)
+		}
+		return fmt.Errorf("failed to move %s into place: %w", s.finalDir, err)
+	}
+
+	if previous != "" {
+		if err := os.RemoveAll(previous); err != nil {
+			printer.PrintWarning("Failed to remove previous install " + previous + ", it is removed on the next run: " + err.Error())
+		}
+	}
+
+	os.Remove(s.stagingDir)
+	return nil
+}
+
+// Discard removes the staged folder, leaving the live install as it was
+func (s *Stage) Discard() error {
+	err := os.RemoveAll(s.Dir)
+	os.Remove(s.stagingDir)
+	return err
+}
@@ -0,0 +1,196 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+	"github.com/UmbrellaCrow612/binman/cli/shared"
+)
+
+// Name of the file recording what was installed, kept inside the bin folder
+const FileName = ".binman-state.json"
+
+// Represents what binman installed into the bin folder
+type State struct {
+	// Installed targets keyed by name/platform/arch
+	Targets map[string]*Entry `json:"targets"`
+}
+
+// Represents a single installed platform and architecture build of a binary
+type Entry struct {
+	Name     string `json:"name"`
+	Platform string `json:"platform"`
+	Arch     string `json:"arch"`
+	URL      string `json:"url"`
+
+	// Hash of the archive as algorithm:hex, see shared.Target.Integrity
+	Integrity string `json:"integrity"`
+
+	// URL the archive was served from after redirects
+	ResolvedURL string `json:"resolved_url"`
+
+	// Size of the archive in bytes
+	Size int64 `json:"size"`
+
+	// Fingerprint of the config the target was installed with, see shared.Target.Fingerprint
+	Fingerprint string `json:"fingerprint"`
+
+	// Files left in bin/<name>/<platform>/<arch> after the install
+	Files []File `json:"files"`
+}
+
+// Represents a file produced by an install
+type File struct {
+	// Path relative to bin/<name>/<platform>/<arch> using forward slashes
+	Path string `json:"path"`
+
+	// SHA256 of the file content
+	SHA256 string `json:"sha256"`
+
+	// Unix permission bits of the file
+	Mode fs.FileMode `json:"mode"`
+}
+
+// Path returns where the state file of a project lives
+func Path(projectPath string) string {
+	return filepath.Join(projectPath, "bin", FileName)
+}
+
+// Load reads the state file of a project, returning an empty state when there is none yet
+func Load(projectPath string) (*State, error) {
+	st := &State{Targets: map[string]*Entry{}}
+
+	data, err := os.ReadFile(Path(projectPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", Path(projectPath), err)
+	}
+
+	if st.Targets == nil {
+		st.Targets = map[string]*Entry{}
+	}
+
+	return st, nil
+}
+
+// Save writes the state file of a project
+func (s *State) Save(projectPath string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := Path(projectPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create bin directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Get returns the entry of an installed target if there is one
+func (s *State) Get(target shared.Target) (*Entry, bool) {
+	entry, ok := s.Targets[target.String()]
+	return entry, ok
+}
+
+// Set records a target as installed from an archive of the given size and with the given files
+func (s *State) Set(target shared.Target, fingerprint string, resolvedURL string, size int64, files []File) {
+	s.Targets[target.String()] = &Entry{
+		Name:        target.Binary.NAME,
+		Platform:    target.Platform,
+		Arch:        target.Arch,
+		URL:         target.URL(),
+		Integrity:   target.Integrity(),
+		ResolvedURL: resolvedURL,
+		Size:        size,
+		Fingerprint: fingerprint,
+		Files:       files,
+	}
+}
+
+// Delete forgets an installed target
+func (s *State) Delete(key string) {
+	delete(s.Targets, key)
+}
+
+// UpToDate reports whether the target is installed with the same config and all of its files still exist
+func (s *State) UpToDate(target shared.Target, fingerprint string, projectPath string) bool {
+	entry, ok := s.Get(target)
+	if !ok || entry.Fingerprint != fingerprint {
+		return false
+	}
+
+	dir := filepath.Join(projectPath, "bin", target.Binary.NAME, target.Platform, target.Arch)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return false
+	}
+
+	for _, file := range entry.Files {
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(file.Path))); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Scan lists every file in dir with its hash and mode, sorted by path
+func Scan(dir string) ([]File, error) {
+	var files []File
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		hash, err := integrity.HashFile(path, "sha256")
+		if err != nil {
+			return err
+		}
+
+		files = append(files, File{
+			Path:   filepath.ToSlash(rel),
+			SHA256: hash,
+			Mode:   info.Mode().Perm(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	return files, nil
+}
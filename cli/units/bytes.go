@@ -0,0 +1,83 @@
+// Package units parses and formats byte sizes such as 512MB or 2GiB
+package units
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Suffixes and their multipliers, decimal for KB, MB.. and binary for KiB, MiB..
+var byteUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"TB", 1000 * 1000 * 1000 * 1000},
+	{"K", 1000},
+	{"M", 1000 * 1000},
+	{"G", 1000 * 1000 * 1000},
+	{"T", 1000 * 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// ParseBytes parses a size such as 1024, 512MB, 1.5GB or 2GiB into bytes. Units are case insensitive.
+func ParseBytes(s string) (int64, error) {
+	value := strings.TrimSpace(s)
+	multiplier := int64(1)
+
+	for _, unit := range byteUnits {
+		if len(value) > len(unit.suffix) && strings.EqualFold(value[len(value)-len(unit.suffix):], unit.suffix) {
+			value = strings.TrimSpace(value[:len(value)-len(unit.suffix)])
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a number of bytes with an optional unit i.e 512MB or 2GiB", s)
+	}
+
+	return int64(number * float64(multiplier)), nil
+}
+
+// FormatBytes formats bytes with the largest unit dividing them exactly, i.e 10 MB or 2 GiB,
+// falling back to one decimal of the largest binary unit below them i.e 1.5 GiB
+func FormatBytes(n int64) string {
+	for _, suffix := range []string{"TiB", "TB", "GiB", "GB", "MiB", "MB", "KiB", "KB"} {
+		multiplier := multiplierOf(suffix)
+		if n >= multiplier && n%multiplier == 0 {
+			return strconv.FormatInt(n/multiplier, 10) + " " + suffix
+		}
+	}
+
+	if n < 1<<10 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+
+	value := float64(n)
+	for _, suffix := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		value /= 1024
+		if value < 1024 || suffix == "TiB" {
+			return strconv.FormatFloat(value, 'f', 1, 64) + " " + suffix
+		}
+	}
+	return ""
+}
+
+// multiplierOf returns the multiplier of a unit suffix
+func multiplierOf(suffix string) int64 {
+	for _, unit := range byteUnits {
+		if unit.suffix == suffix {
+			return unit.multiplier
+		}
+	}
+	return 1
+}
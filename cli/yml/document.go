@@ -0,0 +1,245 @@
+package yml
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/UmbrellaCrow612/binman/cli/integrity"
+	"gopkg.in/yaml.v3"
+)
+
+// Represents binman.yml as its raw bytes and YAML node tree. Edits patch only the lines they
+// touch, so everything else in the file is written back byte for byte.
+type Document struct {
+	path   string
+	data   []byte
+	root   *yaml.Node
+	indent int
+}
+
+// Represents the sha256 or integrity entry of a URL defined in binman.yml
+type Checksum struct {
+	// Position of the binary in the binaries list
+	Index int
+
+	Name     string
+	Platform string
+	Arch     string
+	URL      string
+
+	// Map the entry is in, "integrity" when the binary defines one for the URL and "sha256" otherwise
+	Field string
+
+	// Value in the config, empty when missing
+	Value string
+
+	// Checksum file of the binary, where the hash comes from when Value is empty
+	ChecksumURL string
+}
+
+// String returns the checksum as name/platform/arch for use in messages
+func (c Checksum) String() string {
+	return c.Name + "/" + c.Platform + "/" + c.Arch
+}
+
+// IsSet reports whether the config holds a real hash rather than nothing or a placeholder
+func (c Checksum) IsSet() bool {
+	if c.Field == "integrity" {
+		_, err := integrity.Parse(c.Value)
+		return err == nil
+	}
+	return sha256Pattern.MatchString(c.Value)
+}
+
+// Algorithm returns the hash algorithm of the entry. An integrity placeholder such as "sha512:TODO"
+// names its algorithm, anything else falls back to sha256.
+func (c Checksum) Algorithm() string {
+	if c.Field == "integrity" {
+		name, _, ok := strings.Cut(c.Value, ":")
+		if !ok {
+			name, _, ok = strings.Cut(c.Value, "-")
+		}
+		if _, err := integrity.New(strings.ToLower(name)); ok && err == nil {
+			return strings.ToLower(name)
+		}
+	}
+	return "sha256"
+}
+
+// Matches reports whether the entry holds the given hex hash of its algorithm
+func (c Checksum) Matches(hex string) bool {
+	if c.Field == "integrity" {
+		digest, err := integrity.Parse(c.Value)
+		return err == nil && digest.Hex == hex
+	}
+	return strings.ToLower(c.Value) == hex
+}
+
+// Format returns how a hex hash of the entry's algorithm is written into its field
+func (c Checksum) Format(hex string) string {
+	if c.Field == "integrity" {
+		return c.Algorithm() + ":" + hex
+	}
+	return hex
+}
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Matches the indentation of the first indented line
+var indentPattern = regexp.MustCompile(`(?m)^( +)\S`)
+
+// LoadDocument reads a binman.yml without validating it, so it can be completed
+func LoadDocument(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read file: %w", err)
+	}
+
+	root, err := parseRoot(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse YAML: %w", err)
+	}
+
+	indent := 2
+	if match := indentPattern.FindSubmatch(data); match != nil {
+		indent = len(match[1])
+	}
+
+	return &Document{path: path, data: data, root: root, indent: indent}, nil
+}
+
+// parseRoot parses binman.yml into a node tree whose top level is a mapping
+func parseRoot(data []byte) (*yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("binman.yml must be a mapping")
+	}
+	return &root, nil
+}
+
+// Checksums lists the sha256 or integrity entry of every URL in binman.yml in file order
+func (d *Document) Checksums() ([]Checksum, error) {
+	binaries := mappingValue(d.root.Content[0], "binaries")
+	if binaries == nil || binaries.Kind != yaml.SequenceNode {
+		return nil, errors.New("binman.yml must contain a binaries list")
+	}
+
+	var checksums []Checksum
+	for i, bin := range binaries.Content {
+		if bin.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("binary at index %d must be a mapping", i)
+		}
+
+		name := ""
+		if node := mappingValue(bin, "name"); node != nil {
+			name = node.Value
+		}
+
+		urls := mappingValue(bin, "urls")
+		if urls == nil || urls.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("binary '%s' must define urls", name)
+		}
+		shas := mappingValue(bin, "sha256")
+		integrities := mappingValue(bin, "integrity")
+
+		checksumURL := ""
+		if node := mappingValue(bin, "checksum_url"); node != nil {
+			checksumURL = node.Value
+		}
+
+		for p := 0; p+1 < len(urls.Content); p += 2 {
+			platform, arches := urls.Content[p].Value, urls.Content[p+1]
+			if arches.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("binary '%s' platform '%s' must map architectures to urls", name, platform)
+			}
+
+			for a := 0; a+1 < len(arches.Content); a += 2 {
+				checksum := Checksum{
+					Index:    i,
+					Name:     name,
+					Platform: platform,
+					Arch:     arches.Content[a].Value,
+					URL:      arches.Content[a+1].Value,
+					Field:    "sha256",
+
+					ChecksumURL: checksumURL,
+				}
+				if node := mappingValue(mappingValue(integrities, platform), checksum.Arch); node != nil {
+					checksum.Field = "integrity"
+					checksum.Value = strings.TrimSpace(node.Value)
+				} else if node := mappingValue(mappingValue(shas, platform), checksum.Arch); node != nil {
+					checksum.Value = strings.TrimSpace(node.Value)
+				}
+				checksums = append(checksums, checksum)
+			}
+		}
+	}
+
+	return checksums, nil
+}
+
+// Set writes a hex hash of a checksum into its binary's sha256 or integrity map,
+// adding the map, platform or architecture keys after the existing ones when missing
+func (d *Document) Set(checksum Checksum, hex string) error {
+	bin := mappingValue(d.root.Content[0], "binaries").Content[checksum.Index]
+	change, err := d.entryEdit(bin, []string{checksum.Field, checksum.Platform, checksum.Arch}, checksum.Format(hex))
+	if err != nil {
+		return fmt.Errorf("failed to set %s of %s: %w", checksum.Field, checksum, err)
+	}
+
+	data := make([]byte, 0, len(d.data)+len(change.text))
+	data = append(data, d.data[:change.start]...)
+	data = append(data, change.text...)
+	data = append(data, d.data[change.end:]...)
+
+	// Reparse so positions and keys added by this change are seen by the next one
+	root, err := parseRoot(data)
+	if err != nil {
+		return fmt.Errorf("failed to set %s of %s: %w", checksum.Field, checksum, err)
+	}
+
+	d.data = data
+	d.root = root
+	return nil
+}
+
+// Save writes the document back to the file it was loaded from
+func (d *Document) Save() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, d.data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", d.path, err)
+	}
+
+	return os.Rename(tmp, d.path)
+}
+
+// mappingValue returns the value of key in a mapping node, or nil when it is not there
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	_, value := mappingEntry(mapping, key)
+	return value
+}
+
+// mappingEntry returns the key and value nodes of key in a mapping node, or nils when it is not there
+func mappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
@@ -1,7 +1,9 @@
 package yml
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/UmbrellaCrow612/binman/cli/args"
 	"github.com/UmbrellaCrow612/binman/cli/printer"
@@ -15,22 +17,35 @@ func Parse(opts *args.Options) *shared.Config {
 		printer.ExitError("PathToFile is empty")
 	}
 
+	cfg, err := Load(opts)
+	if err != nil {
+		printer.ExitError(err.Error())
+	}
+
+	printer.PrintSuccess("YAML file parsed successfully")
+
+	return cfg
+}
+
+// Load reads and validates the YAML file from opts.PathToFile without printing anything
+func Load(opts *args.Options) (*shared.Config, error) {
 	data, err := os.ReadFile(opts.PathToFile)
 	if err != nil {
-		printer.ExitError("Failed to read file: " + err.Error())
+		return nil, fmt.Errorf("Failed to read file: %w", err)
 	}
 
 	var cfg shared.Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		printer.ExitError("Failed to parse YAML: " + err.Error())
+		return nil, fmt.Errorf("Failed to parse YAML: %w", err)
 	}
 
-	err = cfg.ValidateWithOptions(opts)
-	if err != nil {
-		printer.ExitError("Failed to parse YAML: " + err.Error())
+	if err := cfg.LoadKeyFiles(filepath.Dir(opts.PathToFile)); err != nil {
+		return nil, fmt.Errorf("Failed to parse YAML: %w", err)
 	}
 
-	printer.PrintSuccess("YAML file parsed successfully")
+	if err := cfg.ValidateWithOptions(opts); err != nil {
+		return nil, fmt.Errorf("Failed to parse YAML: %w", err)
+	}
 
-	return &cfg
+	return &cfg, nil
 }
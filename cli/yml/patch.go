@@ -0,0 +1,225 @@
+package yml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Represents a change to the raw bytes of binman.yml, replacing data[start:end] with text
+type edit struct {
+	start int
+	end   int
+	text  string
+}
+
+// entryEdit returns the change that stores value under keys nested in mapping,
+// adding whichever keys are missing after the existing ones
+func (d *Document) entryEdit(mapping *yaml.Node, keys []string, value string) (edit, error) {
+	for i, name := range keys {
+		key, node := mappingEntry(mapping, name)
+		switch {
+		case key == nil:
+			return d.addEntry(mapping, keys[i:], value)
+		case i == len(keys)-1:
+			return d.setValue(node, value)
+		case node.Kind != yaml.MappingNode:
+			// i.e "linux:" with nothing under it yet
+			return d.fillEntry(mapping, key, node, keys[i+1:], value)
+		}
+		mapping = node
+	}
+	return edit{}, errors.New("no keys to set")
+}
+
+// setValue overwrites an existing scalar with value, keeping its quotes
+func (d *Document) setValue(node *yaml.Node, value string) (edit, error) {
+	start := offset(d.data, node.Line, node.Column)
+	end, err := d.nodeEnd(node)
+	if err != nil {
+		return edit{}, err
+	}
+
+	switch {
+	case node.Tag == "!!null" && start == end:
+		// i.e "x64:" with nothing after it
+		return afterColon(d.data, start, value), nil
+	case node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0:
+		return edit{start: start + 1, end: end - 1, text: value}, nil
+	}
+	return edit{start: start, end: end, text: value}, nil
+}
+
+// addEntry adds keys nested under each other to the end of mapping, with value under the last key
+func (d *Document) addEntry(mapping *yaml.Node, keys []string, value string) (edit, error) {
+	if mapping.Style&yaml.FlowStyle != 0 {
+		if len(mapping.Content) == 0 {
+			open := bytes.IndexByte(d.data[offset(d.data, mapping.Line, mapping.Column):], '{')
+			if open < 0 {
+				return edit{}, fmt.Errorf("line %d: flow mapping has no opening brace", mapping.Line)
+			}
+			pos := offset(d.data, mapping.Line, mapping.Column) + open + 1
+			return edit{start: pos, end: pos, text: flowEntry(keys, value)}, nil
+		}
+
+		end, err := d.nodeEnd(mapping.Content[len(mapping.Content)-1])
+		if err != nil {
+			return edit{}, err
+		}
+		return edit{start: end, end: end, text: ", " + flowEntry(keys, value)}, nil
+	}
+
+	end, err := d.nodeEnd(mapping)
+	if err != nil {
+		return edit{}, err
+	}
+	pos := lineEnd(d.data, end)
+	return edit{start: pos, end: pos, text: blockEntry(keys, value, mapping.Content[0].Column-1, d.indent, lineBreak(d.data))}, nil
+}
+
+// fillEntry writes keys nested under each other as the value of key, which has nothing after it yet
+func (d *Document) fillEntry(parent, key, empty *yaml.Node, keys []string, value string) (edit, error) {
+	if empty.Kind != yaml.ScalarNode || empty.Tag != "!!null" || empty.Value != "" {
+		return edit{}, fmt.Errorf("line %d: '%s' must be a mapping", key.Line, key.Value)
+	}
+
+	pos := offset(d.data, empty.Line, empty.Column)
+	if parent.Style&yaml.FlowStyle != 0 {
+		return afterColon(d.data, pos, "{"+flowEntry(keys, value)+"}"), nil
+	}
+
+	pos = lineEnd(d.data, pos)
+	return edit{start: pos, end: pos, text: blockEntry(keys, value, key.Column-1+d.indent, d.indent, lineBreak(d.data))}, nil
+}
+
+// afterColon inserts text for an empty value at pos, reusing the space after the key's colon
+// unless a comment starts straight after it. Empty values in flow mappings sit on the next token
+// instead of right after the colon.
+func afterColon(data []byte, pos int, text string) edit {
+	if pos > 0 && data[pos-1] == ' ' {
+		return edit{start: pos, end: pos, text: text}
+	}
+	if pos+1 < len(data) && data[pos] == ' ' && data[pos+1] != '#' {
+		return edit{start: pos + 1, end: pos + 1, text: text}
+	}
+	return edit{start: pos, end: pos, text: " " + text}
+}
+
+// nodeEnd returns the offset just past the text of node
+func (d *Document) nodeEnd(node *yaml.Node) (int, error) {
+	start := offset(d.data, node.Line, node.Column)
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		return start + len("*") + len(node.Value), nil
+
+	case yaml.MappingNode, yaml.SequenceNode:
+		if node.Style&yaml.FlowStyle == 0 {
+			return d.nodeEnd(node.Content[len(node.Content)-1])
+		}
+
+		closing := byte('}')
+		if node.Kind == yaml.SequenceNode {
+			closing = ']'
+		}
+		from := start
+		if len(node.Content) > 0 {
+			end, err := d.nodeEnd(node.Content[len(node.Content)-1])
+			if err != nil {
+				return 0, err
+			}
+			from = end
+		}
+		i := bytes.IndexByte(d.data[from:], closing)
+		if i < 0 {
+			return 0, fmt.Errorf("line %d: flow collection is not closed", node.Line)
+		}
+		return from + i + 1, nil
+
+	case yaml.ScalarNode:
+		switch {
+		case node.Style&yaml.DoubleQuotedStyle != 0:
+			for i := start + 1; i < len(d.data); i++ {
+				switch d.data[i] {
+				case '\\':
+					i++
+				case '"':
+					return i + 1, nil
+				}
+			}
+		case node.Style&yaml.SingleQuotedStyle != 0:
+			for i := start + 1; i < len(d.data); i++ {
+				if d.data[i] != '\'' {
+					continue
+				}
+				if i+1 < len(d.data) && d.data[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1, nil
+			}
+		case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0:
+			// Plain scalars on a single line are stored as written
+			if bytes.HasPrefix(d.data[start:], []byte(node.Value)) {
+				return start + len(node.Value), nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("line %d: value cannot be updated in place", node.Line)
+}
+
+// offset converts the 1-based line and column of a node, counted in characters, into a byte offset
+func offset(data []byte, line, column int) int {
+	pos := 0
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(data[pos:], '\n')
+		if i < 0 {
+			return len(data)
+		}
+		pos += i + 1
+	}
+
+	for c := 1; c < column && pos < len(data); c++ {
+		_, size := utf8.DecodeRune(data[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// lineEnd returns the offset of the line break ending the line that holds pos, trailing comments included
+func lineEnd(data []byte, pos int) int {
+	i := bytes.IndexAny(data[pos:], "\r\n")
+	if i < 0 {
+		return len(data)
+	}
+	return pos + i
+}
+
+// lineBreak returns the line break the file uses
+func lineBreak(data []byte) string {
+	if bytes.Contains(data, []byte("\r\n")) {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// blockEntry writes keys as block mapping lines, each nested under the previous one, with value after the last.
+// The text starts with a line break so it can be inserted at the end of a line.
+func blockEntry(keys []string, value string, indent, step int, newline string) string {
+	var b strings.Builder
+	for i, key := range keys {
+		b.WriteString(newline + strings.Repeat(" ", indent+i*step) + key + ":")
+	}
+	b.WriteString(" " + value)
+	return b.String()
+}
+
+// flowEntry writes keys as a flow mapping entry, each nested under the previous one, with value after the last
+func flowEntry(keys []string, value string) string {
+	return strings.Join(keys, ": {") + ": " + value + strings.Repeat("}", len(keys)-1)
+}